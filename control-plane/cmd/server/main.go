@@ -9,6 +9,8 @@ import (
 	"github.com/bizjs/Lograil/control-plane/internal/api"
 	"github.com/bizjs/Lograil/control-plane/internal/config"
 	"github.com/bizjs/Lograil/control-plane/internal/database"
+	"github.com/bizjs/Lograil/control-plane/internal/victorialogs"
+	"github.com/bizjs/Lograil/pkg/tenant"
 )
 
 func main() {
@@ -30,8 +32,21 @@ func main() {
 		log.Fatalf("Failed to run migrations: %v", err)
 	}
 
+	// Initialize the Ent client used to verify project API keys
+	client, err := database.NewEntClient(db)
+	if err != nil {
+		log.Fatalf("Failed to create database client: %v", err)
+	}
+	defer client.Close()
+
+	// Initialize VictoriaLogs query client
+	logs := victorialogs.NewClient(cfg.VictoriaLogsURL, tenant.Mapping{
+		Mode:      cfg.TenantMode,
+		AccountID: cfg.TenantAccountID,
+	})
+
 	// Initialize API server
-	server := api.NewServer(cfg, db)
+	server := api.NewServer(cfg, db, client, logs)
 
 	// Start server in a goroutine
 	go func() {
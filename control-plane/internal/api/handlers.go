@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"github.com/bizjs/Lograil/control-plane/internal/victorialogs"
 	"github.com/gin-gonic/gin"
 )
 
@@ -173,7 +174,7 @@ func (s *Server) deleteProject(c *gin.Context) {
 
 func (s *Server) getProjectLogs(c *gin.Context) {
 	id := c.Param("id")
-	_, err := strconv.Atoi(id)
+	projectID, err := strconv.Atoi(id)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project ID"})
 		return
@@ -183,15 +184,24 @@ func (s *Server) getProjectLogs(c *gin.Context) {
 	query := c.Query("query")
 	start := c.Query("start")
 	end := c.Query("end")
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
 
-	// TODO: Implement log querying logic with VictoriaLogs
-	logs := []gin.H{
-		{
-			"timestamp": "2024-01-01T00:00:00Z",
-			"level":     "info",
-			"message":   "Sample log entry",
-			"source":    "app",
-		},
+	// The client confines the query to the project's tenant
+	logs, err := s.logs.Query(c.Request.Context(), projectID, query, victorialogs.QueryOptions{
+		Start: start,
+		End:   end,
+		Limit: limit,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":   "Failed to query logs",
+			"details": err.Error(),
+		})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bizjs/Lograil/pkg/apikey"
+	"github.com/bizjs/Lograil/pkg/data"
+	dataapikey "github.com/bizjs/Lograil/pkg/data/apikey"
+	"github.com/gin-gonic/gin"
+)
+
+// projectKeyAuth requires an API key with read permission that belongs to
+// the project in the :id parameter, sent either as "Authorization: Bearer
+// <key>" or "X-API-Key: <key>".
+func (s *Server) projectKeyAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rawKey := apikey.FromHeader(c.Request.Header)
+		if rawKey == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "API key required"})
+			return
+		}
+
+		key, err := s.data.APIKey.Query().
+			Where(dataapikey.HashedKey(apikey.Hash(rawKey))).
+			WithProject().
+			Only(c.Request.Context())
+		if data.IsNotFound(err) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid API key"})
+			return
+		}
+		if err != nil {
+			log.Printf("API key verification failed: %v", err)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify API key"})
+			return
+		}
+
+		switch {
+		case !key.IsActive:
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "API key is inactive"})
+		case !key.ExpiresAt.IsZero() && time.Now().After(key.ExpiresAt):
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "API key has expired"})
+		case !apikey.HasPermission(key.Permissions, apikey.PermissionRead):
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API key lacks read permission"})
+		case key.Edges.Project == nil || strconv.Itoa(key.Edges.Project.ID) != c.Param("id"):
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API key does not belong to this project"})
+		default:
+			c.Next()
+		}
+	}
+}
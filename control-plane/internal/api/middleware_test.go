@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/bizjs/Lograil/control-plane/internal/config"
+	"github.com/bizjs/Lograil/control-plane/internal/database"
+	"github.com/bizjs/Lograil/control-plane/internal/victorialogs"
+	"github.com/bizjs/Lograil/pkg/apikey"
+	"github.com/bizjs/Lograil/pkg/tenant"
+	"github.com/gin-gonic/gin"
+)
+
+func TestProjectLogsRequireProjectKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := database.NewConnection("file:logs_auth_test?mode=memory&cache=shared&_fk=1")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	client, err := database.NewEntClient(db)
+	if err != nil {
+		t.Fatalf("Failed to create Ent client: %v", err)
+	}
+
+	ctx := context.Background()
+	if err := client.Schema.Create(ctx); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+	owner := client.User.Create().
+		SetUsername("owner").
+		SetEmail("owner@example.com").
+		SetPasswordHash("hashedpassword").
+		SaveX(ctx)
+	shop := client.Project.Create().SetName("shop").SetOwner(owner).SaveX(ctx)
+	blog := client.Project.Create().SetName("blog").SetOwner(owner).SaveX(ctx)
+	for raw, project := range map[string]int{"shop-key": shop.ID, "blog-key": blog.ID} {
+		client.APIKey.Create().
+			SetName(raw).
+			SetHashedKey(apikey.Hash(raw)).
+			SetProjectID(project).
+			SetCreatedBy(owner).
+			SaveX(ctx)
+	}
+
+	var projectHeader, streamFilter string
+	vl := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		projectHeader = r.Header.Get("ProjectID")
+		streamFilter = r.FormValue("extra_stream_filters")
+	}))
+	defer vl.Close()
+
+	get := func(mode tenant.Mode, key string) int {
+		logs := victorialogs.NewClient(vl.URL, tenant.Mapping{Mode: mode})
+		server := NewServer(&config.Config{}, db, client, logs)
+
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/projects/"+strconv.Itoa(shop.ID)+"/logs", nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		server.router.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	if code := get(tenant.ModeHeaders, ""); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a key, got %d", code)
+	}
+	if code := get(tenant.ModeHeaders, "blog-key"); code != http.StatusForbidden {
+		t.Errorf("Expected 403 for another project's key, got %d", code)
+	}
+	if code := get(tenant.ModeHeaders, "shop-key"); code != http.StatusOK || projectHeader != strconv.Itoa(shop.ID) {
+		t.Errorf("Expected the query in the project's tenant, got %d with ProjectID %q", code, projectHeader)
+	}
+	if code := get(tenant.ModeStreamField, "shop-key"); code != http.StatusOK || streamFilter != `{project_id="`+strconv.Itoa(shop.ID)+`"}` {
+		t.Errorf("Expected the project stream filter, got %d with %q", code, streamFilter)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"time"
 
 	"github.com/bizjs/Lograil/control-plane/internal/config"
+	"github.com/bizjs/Lograil/control-plane/internal/victorialogs"
+	"github.com/bizjs/Lograil/pkg/data"
 	"github.com/gin-gonic/gin"
 )
 
@@ -14,10 +16,12 @@ type Server struct {
 	router *gin.Engine
 	server *http.Server
 	db     *sql.DB
+	data   *data.Client
 	config *config.Config
+	logs   *victorialogs.Client
 }
 
-func NewServer(cfg *config.Config, db *sql.DB) *Server {
+func NewServer(cfg *config.Config, db *sql.DB, client *data.Client, logs *victorialogs.Client) *Server {
 	if cfg.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
@@ -32,7 +36,9 @@ func NewServer(cfg *config.Config, db *sql.DB) *Server {
 	server := &Server{
 		router: router,
 		db:     db,
+		data:   client,
 		config: cfg,
+		logs:   logs,
 		server: &http.Server{
 			Addr:    ":" + cfg.ServerPort,
 			Handler: router,
@@ -78,8 +84,8 @@ func (s *Server) setupRoutes() {
 				projects.PUT("/:id", s.updateProject)
 				projects.DELETE("/:id", s.deleteProject)
 
-				// Project logs
-				projects.GET("/:id/logs", s.getProjectLogs)
+				// Project logs, readable with a key of the project itself
+				projects.GET("/:id/logs", s.projectKeyAuth(), s.getProjectLogs)
 			}
 
 			// Configuration routes
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
+
+	"github.com/bizjs/Lograil/pkg/tenant"
 )
 
 type Config struct {
@@ -11,6 +14,11 @@ type Config struct {
 	RedisURL    string
 	JWTSecret   string
 	Environment string
+
+	// VictoriaLogs query access, isolated per project
+	VictoriaLogsURL string
+	TenantMode      tenant.Mode
+	TenantAccountID uint32
 }
 
 func Load() (*Config, error) {
@@ -20,7 +28,21 @@ func Load() (*Config, error) {
 		RedisURL:    getEnv("REDIS_URL", "redis://localhost:6379"),
 		JWTSecret:   getEnv("JWT_SECRET", "your-secret-key-change-in-production"),
 		Environment: getEnv("ENVIRONMENT", "development"),
+
+		VictoriaLogsURL: getEnv("VICTORIA_LOGS_URL", "http://localhost:9428"),
+	}
+
+	tenantMode, err := tenant.ParseMode(getEnv("TENANT_MODE", string(tenant.ModeHeaders)))
+	if err != nil {
+		return nil, err
+	}
+	cfg.TenantMode = tenantMode
+
+	accountID, err := strconv.ParseUint(getEnv("TENANT_ACCOUNT_ID", "0"), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TENANT_ACCOUNT_ID: %w", err)
 	}
+	cfg.TenantAccountID = uint32(accountID)
 
 	return cfg, nil
 }
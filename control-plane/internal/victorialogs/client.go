@@ -0,0 +1,102 @@
+package victorialogs
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bizjs/Lograil/pkg/tenant"
+)
+
+// Client queries VictoriaLogs on behalf of a project. Every query is
+// confined to the project's tenant using the same mapping the ingestion
+// service writes with.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	tenant     tenant.Mapping
+}
+
+// QueryOptions are the optional parameters of a LogsQL query.
+type QueryOptions struct {
+	Start string
+	End   string
+	Limit int
+}
+
+func NewClient(baseURL string, mapping tenant.Mapping) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+		tenant: mapping,
+	}
+}
+
+// Query runs a LogsQL query for a project and returns the matching entries.
+func (c *Client) Query(ctx context.Context, projectID int, query string, opts QueryOptions) ([]map[string]interface{}, error) {
+	if strings.TrimSpace(query) == "" {
+		query = "*"
+	}
+
+	params := url.Values{}
+	params.Set("query", query)
+	if opts.Start != "" {
+		params.Set("start", opts.Start)
+	}
+	if opts.End != "" {
+		params.Set("end", opts.End)
+	}
+	if opts.Limit > 0 {
+		params.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	c.tenant.RestrictQuery(params, projectID)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/select/logsql/query", strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	c.tenant.SetHeaders(req.Header, projectID)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query VictoriaLogs: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("VictoriaLogs returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	// The response is one JSON object per line
+	logs := []map[string]interface{}{}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var entry map[string]interface{}
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("failed to decode query result: %w", err)
+		}
+		logs = append(logs, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read query result: %w", err)
+	}
+
+	return logs, nil
+}
@@ -1,14 +1,25 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 
 	"github.com/bizjs/Lograil/ingestion/internal/api"
+	"github.com/bizjs/Lograil/ingestion/internal/auth"
+	"github.com/bizjs/Lograil/ingestion/internal/buffer"
 	"github.com/bizjs/Lograil/ingestion/internal/config"
+	"github.com/bizjs/Lograil/ingestion/internal/database"
+	"github.com/bizjs/Lograil/ingestion/internal/listener"
+	"github.com/bizjs/Lograil/ingestion/internal/metrics"
+	"github.com/bizjs/Lograil/ingestion/internal/pipeline"
+	"github.com/bizjs/Lograil/ingestion/internal/sink"
+	"github.com/bizjs/Lograil/ingestion/internal/spool"
 	"github.com/bizjs/Lograil/ingestion/internal/storage"
+	"github.com/bizjs/Lograil/pkg/tenant"
 )
 
 func main() {
@@ -18,15 +29,94 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
-	// Initialize VictoriaLogs storage
-	victoriaLogs, err := storage.NewVictoriaLogsClient(cfg.VictoriaLogsURL)
+	// Initialize Prometheus metrics
+	serverMetrics := metrics.New()
+
+	// Sinks that spool share SPOOL_MAX_BYTES equally, so the spools
+	// together stay within it
+	spoolMaxBytes := cfg.SpoolMaxBytes
+	spooling := 0
+	for _, sinkConfig := range cfg.Sinks {
+		if sinkConfig.OnFailure == sink.PolicySpool {
+			spooling++
+		}
+	}
+	if spooling > 1 {
+		spoolMaxBytes /= int64(spooling)
+	}
+
+	// Initialize the storage sinks every entry is fanned out to
+	var sinkConfigs []sink.Config
+	for _, sinkConfig := range cfg.Sinks {
+		out, err := openSink(cfg, sinkConfig.Name)
+		if err != nil {
+			log.Fatalf("Failed to open %s sink: %v", sinkConfig.Name, err)
+		}
+
+		// The primary sink spools to SPOOL_DIR itself, so existing segments
+		// are still replayed
+		spoolDir := cfg.SpoolDir
+		if sinkConfig.Name != config.SinkVictoriaLogs {
+			spoolDir = filepath.Join(cfg.SpoolDir, sinkConfig.Name)
+		}
+
+		sinkConfigs = append(sinkConfigs, sink.Config{
+			Name: sinkConfig.Name,
+			Sink: out,
+			Buffer: buffer.Options{
+				Capacity:      sinkConfig.BufferSize,
+				BatchSize:     sinkConfig.BatchSize,
+				FlushInterval: sinkConfig.FlushInterval,
+				Workers:       sinkConfig.Workers,
+			},
+			OnFailure: sinkConfig.OnFailure,
+			Spool: spool.Options{
+				Dir:            spoolDir,
+				MaxBytes:       spoolMaxBytes,
+				SegmentBytes:   cfg.SpoolSegmentBytes,
+				ReplayInterval: cfg.SpoolReplayInterval,
+			},
+			OnWrite: serverMetrics.WriteObserver(sinkConfig.Name),
+		})
+	}
+	sinks, err := sink.New(sinkConfigs)
 	if err != nil {
-		log.Fatalf("Failed to connect to VictoriaLogs: %v", err)
+		log.Fatalf("Failed to start sinks: %v", err)
+	}
+
+	// Initialize API key verification against the metadata database
+	var keys *auth.KeyStore
+	if cfg.AuthEnabled {
+		client, err := database.NewEntClient(cfg.DatabaseURL)
+		if err != nil {
+			log.Fatalf("Failed to connect to database: %v", err)
+		}
+		defer client.Close()
+
+		keys = auth.NewKeyStore(client, auth.Options{
+			CacheTTL:      cfg.APIKeyCacheTTL,
+			TouchInterval: cfg.APIKeyTouchInterval,
+		})
+		defer keys.Close()
+	} else {
+		log.Println("API key authentication is disabled")
+	}
+
+	// Initialize the parsing pipeline
+	var processors *pipeline.Pipeline
+	if cfg.PipelineConfig != "" {
+		pipelineConfig, err := pipeline.Load(cfg.PipelineConfig)
+		if err != nil {
+			log.Fatalf("Failed to load pipeline config: %v", err)
+		}
+		processors, err = pipeline.New(pipelineConfig)
+		if err != nil {
+			log.Fatalf("Invalid pipeline config: %v", err)
+		}
 	}
-	defer victoriaLogs.Close()
 
 	// Initialize API server
-	server := api.NewServer(cfg, victoriaLogs)
+	server := api.NewServer(cfg, sinks, keys, processors, serverMetrics)
 
 	// Start server in a goroutine
 	go func() {
@@ -36,15 +126,113 @@ func main() {
 		}
 	}()
 
+	// Start syslog listeners
+	var syslogServer *listener.SyslogServer
+	if cfg.SyslogUDPAddr != "" || cfg.SyslogTCPAddr != "" {
+		syslogServer = listener.NewSyslogServer(listener.SyslogOptions{
+			UDPAddr:        cfg.SyslogUDPAddr,
+			TCPAddr:        cfg.SyslogTCPAddr,
+			ProjectID:      cfg.SyslogProjectID,
+			MaxMessageSize: cfg.SyslogMaxMessageSize,
+		}, server.Ingest)
+		if err := syslogServer.Start(); err != nil {
+			log.Fatalf("Failed to start syslog listener: %v", err)
+		}
+		log.Printf("Listening for syslog on udp=%q tcp=%q", cfg.SyslogUDPAddr, cfg.SyslogTCPAddr)
+	}
+
+	// Start Fluentd Forward listener
+	var forwardServer *listener.ForwardServer
+	if cfg.ForwardAddr != "" {
+		forwardServer = listener.NewForwardServer(listener.ForwardOptions{
+			Addr:           cfg.ForwardAddr,
+			ProjectID:      cfg.ForwardProjectID,
+			MaxMessageSize: cfg.ForwardMaxMessageSize,
+		}, server.Ingest)
+		if err := forwardServer.Start(); err != nil {
+			log.Fatalf("Failed to start forward listener: %v", err)
+		}
+		log.Printf("Listening for Fluentd Forward on %s", cfg.ForwardAddr)
+	}
+
+	// Start GELF listeners
+	var gelfServer *listener.GELFServer
+	if cfg.GELFUDPAddr != "" || cfg.GELFTCPAddr != "" {
+		gelfServer = listener.NewGELFServer(listener.GELFOptions{
+			UDPAddr:        cfg.GELFUDPAddr,
+			TCPAddr:        cfg.GELFTCPAddr,
+			ProjectID:      cfg.GELFProjectID,
+			MaxMessageSize: cfg.GELFMaxMessageSize,
+			ChunkTimeout:   cfg.GELFChunkTimeout,
+		}, server.Ingest)
+		if err := gelfServer.Start(); err != nil {
+			log.Fatalf("Failed to start GELF listener: %v", err)
+		}
+		log.Printf("Listening for GELF on udp=%q tcp=%q", cfg.GELFUDPAddr, cfg.GELFTCPAddr)
+	}
+
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Println("Shutting down server...")
 
+	// Stop protocol listeners before the HTTP server drains the buffer
+	if syslogServer != nil {
+		syslogServer.Close()
+	}
+	if forwardServer != nil {
+		forwardServer.Close()
+	}
+	if gelfServer != nil {
+		gelfServer.Close()
+	}
+
 	if err := server.Shutdown(); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
 	log.Println("Server exited")
 }
+
+// openSink creates the storage sink named in SINKS.
+func openSink(cfg *config.Config, name string) (storage.Sink, error) {
+	switch name {
+	case config.SinkVictoriaLogs:
+		return storage.NewVictoriaLogsClient(cfg.VictoriaLogsURL, victoriaLogsOptions(cfg))
+	case config.SinkSecondaryVictoriaLogs:
+		return storage.NewVictoriaLogsClient(cfg.SecondaryVictoriaLogsURL, victoriaLogsOptions(cfg))
+	case config.SinkFile:
+		return storage.NewFileSink(storage.FileOptions{
+			Dir:      cfg.FileSinkDir,
+			MaxBytes: cfg.FileSinkMaxBytes,
+			MaxFiles: cfg.FileSinkMaxFiles,
+		})
+	case config.SinkStdout:
+		return storage.NewWriterSink(os.Stdout), nil
+	default:
+		return nil, fmt.Errorf("unknown sink %q", name)
+	}
+}
+
+// victoriaLogsOptions returns the client options shared by the primary and
+// secondary VictoriaLogs sinks.
+func victoriaLogsOptions(cfg *config.Config) storage.VictoriaLogsOptions {
+	return storage.VictoriaLogsOptions{
+		StreamFields: cfg.StreamFields,
+		TimeField:    cfg.TimeField,
+		MsgField:     cfg.MsgField,
+
+		Timeout:          cfg.VictoriaLogsTimeout,
+		MaxRetries:       cfg.WriteMaxRetries,
+		RetryBaseDelay:   cfg.WriteRetryBaseDelay,
+		RetryMaxDelay:    cfg.WriteRetryMaxDelay,
+		BreakerThreshold: cfg.BreakerThreshold,
+		BreakerCooldown:  cfg.BreakerCooldown,
+
+		Tenant: tenant.Mapping{
+			Mode:      cfg.TenantMode,
+			AccountID: cfg.TenantAccountID,
+		},
+	}
+}
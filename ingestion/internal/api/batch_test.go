@@ -0,0 +1,64 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bizjs/Lograil/ingestion/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+func TestIngestBatchPartialFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	server, _ := newTestServer(t, config.Config{})
+
+	post := func(body string) (int, batchResponse) {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/ingest/batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		server.router.ServeHTTP(recorder, req)
+
+		var resp batchResponse
+		if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Invalid response: %v", err)
+		}
+		return recorder.Code, resp
+	}
+
+	code, resp := post(`{"logs":[
+		{"level":"info","message":"one","source":"s"},
+		{"level":"info","source":"s"},
+		"not an object",
+		{"level":"info","message":"bad time","source":"s","timestamp":"yesterday"},
+		{"level":"warn","message":"two","source":"s"}
+	]}`)
+	if code != http.StatusAccepted || resp.Accepted != 2 || resp.Rejected != 3 {
+		t.Fatalf("Expected 2 accepted and 3 rejected, got %d: %+v", code, resp)
+	}
+	for i, index := range []int{1, 2, 3} {
+		if resp.Errors[i].Index != index || resp.Errors[i].Status != http.StatusBadRequest || resp.Errors[i].Error == "" {
+			t.Errorf("Unexpected entry error %d: %+v", i, resp.Errors[i])
+		}
+	}
+	if !strings.Contains(resp.Errors[0].Error, "message") {
+		t.Errorf("Expected the missing field to be named: %q", resp.Errors[0].Error)
+	}
+	if server.buffer.Len() != 2 {
+		t.Errorf("Expected 2 buffered entries, got %d", server.buffer.Len())
+	}
+
+	code, resp = post(`{"logs":[{"level":"info"}]}`)
+	if code != http.StatusBadRequest || resp.Rejected != 1 || len(resp.Errors) != 1 {
+		t.Errorf("Expected 400 when every entry is invalid, got %d: %+v", code, resp)
+	}
+}
+
+type batchResponse struct {
+	Accepted int          `json:"accepted"`
+	Rejected int          `json:"rejected"`
+	Errors   []entryError `json:"errors"`
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+var snappyStreamHeader = []byte("\xff\x06\x00\x00sNaPpY")
+
+// decompressBody transparently decodes request bodies sent with a
+// Content-Encoding of gzip, deflate, zstd or snappy. The decoded body is
+// capped at maxSize to guard against decompression bombs; reading past it
+// fails with *http.MaxBytesError.
+func decompressBody(maxSize int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Content-Encoding")
+		if header == "" {
+			c.Next()
+			return
+		}
+
+		// Encodings are listed in the order they were applied
+		var body io.Reader = c.Request.Body
+		var closers []io.Closer
+		encodings := strings.Split(header, ",")
+		for i := len(encodings) - 1; i >= 0; i-- {
+			encoding := strings.ToLower(strings.TrimSpace(encodings[i]))
+			reader, err := newBodyDecoder(encoding, body, maxSize)
+			if err != nil {
+				for _, closer := range closers {
+					closer.Close()
+				}
+				if err == errUnsupportedEncoding {
+					c.AbortWithStatusJSON(http.StatusUnsupportedMediaType, gin.H{
+						"error": fmt.Sprintf("unsupported Content-Encoding %q", encoding),
+					})
+					return
+				}
+				respondBodyError(c, fmt.Errorf("invalid %s body: %w", encoding, err))
+				c.Abort()
+				return
+			}
+			if closer, ok := reader.(io.Closer); ok {
+				closers = append(closers, closer)
+			}
+			body = reader
+		}
+		defer func() {
+			for _, closer := range closers {
+				closer.Close()
+			}
+		}()
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, io.NopCloser(body), maxSize)
+		c.Request.Header.Del("Content-Encoding")
+		c.Request.ContentLength = -1
+
+		c.Next()
+	}
+}
+
+var errUnsupportedEncoding = errors.New("unsupported content encoding")
+
+func newBodyDecoder(encoding string, body io.Reader, maxSize int64) (io.Reader, error) {
+	switch encoding {
+	case "identity", "":
+		return body, nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(body)
+	case "deflate":
+		// HTTP deflate is zlib-wrapped, but raw deflate is common in practice
+		buffered := bufio.NewReader(body)
+		header, _ := buffered.Peek(2)
+		if len(header) == 2 && header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+			return zlib.NewReader(buffered)
+		}
+		return flate.NewReader(buffered), nil
+	case "zstd":
+		// The window limit also bounds memory for frames without a size
+		decoder, err := zstd.NewReader(body,
+			zstd.WithDecoderConcurrency(1),
+			zstd.WithDecoderMaxMemory(max(uint64(maxSize), 8<<20)))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	case "snappy":
+		return newSnappyReader(body, maxSize)
+	default:
+		return nil, errUnsupportedEncoding
+	}
+}
+
+// newSnappyReader accepts both the framed stream format and the block
+// format used by Prometheus-style clients.
+func newSnappyReader(body io.Reader, maxSize int64) (io.Reader, error) {
+	buffered := bufio.NewReader(body)
+	if header, _ := buffered.Peek(len(snappyStreamHeader)); bytes.Equal(header, snappyStreamHeader) {
+		return snappy.NewReader(buffered), nil
+	}
+
+	compressed, err := io.ReadAll(io.LimitReader(buffered, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(compressed)) > maxSize {
+		return nil, &http.MaxBytesError{Limit: maxSize}
+	}
+	decodedLen, err := snappy.DecodedLen(compressed)
+	if err != nil {
+		return nil, err
+	}
+	if int64(decodedLen) > maxSize {
+		return nil, &http.MaxBytesError{Limit: maxSize}
+	}
+	decoded, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(decoded), nil
+}
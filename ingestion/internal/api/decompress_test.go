@@ -0,0 +1,85 @@
+package api
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+func compressWith(t *testing.T, newWriter func(io.Writer) io.WriteCloser, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := newWriter(&buf)
+	if _, err := io.WriteString(w, data); err != nil {
+		t.Fatalf("Compress failed: %v", err)
+	}
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestDecompressBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(decompressBody(64))
+	router.POST("/", func(c *gin.Context) {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			respondBodyError(c, err)
+			return
+		}
+		c.String(http.StatusOK, string(body))
+	})
+
+	payload := `{"message":"hello"}`
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		status   int
+	}{
+		{"identity", "", []byte(payload), http.StatusOK},
+		{"gzip", "gzip", compressWith(t, func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }, payload), http.StatusOK},
+		{"zlib deflate", "deflate", compressWith(t, func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }, payload), http.StatusOK},
+		{"raw deflate", "deflate", compressWith(t, func(w io.Writer) io.WriteCloser {
+			fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+			return fw
+		}, payload), http.StatusOK},
+		{"zstd", "zstd", compressWith(t, func(w io.Writer) io.WriteCloser {
+			zw, _ := zstd.NewWriter(w)
+			return zw
+		}, payload), http.StatusOK},
+		{"snappy block", "snappy", snappy.Encode(nil, []byte(payload)), http.StatusOK},
+		{"snappy framed", "snappy", compressWith(t, func(w io.Writer) io.WriteCloser { return snappy.NewBufferedWriter(w) }, payload), http.StatusOK},
+		{"unsupported", "br", []byte(payload), http.StatusUnsupportedMediaType},
+		{"invalid", "gzip", []byte(payload), http.StatusBadRequest},
+		{"too large", "gzip", compressWith(t, func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }, strings.Repeat("a", 1024)), http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(tt.body))
+			if tt.encoding != "" {
+				req.Header.Set("Content-Encoding", tt.encoding)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.status {
+				t.Fatalf("Expected %d, got %d: %s", tt.status, recorder.Code, recorder.Body)
+			}
+			if tt.status == http.StatusOK && recorder.Body.String() != payload {
+				t.Errorf("Unexpected body %q", recorder.Body)
+			}
+		})
+	}
+}
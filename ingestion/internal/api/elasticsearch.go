@@ -0,0 +1,101 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/bizjs/Lograil/ingestion/internal/parser"
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// elasticsearchVersion is reported to shippers that probe the cluster
+// version before sending bulk requests.
+const elasticsearchVersion = "8.11.0"
+
+// Elasticsearch cluster info, probed by Filebeat and Logstash on startup
+func (s *Server) elasticsearchInfo(c *gin.Context) {
+	c.Header("X-Elastic-Product", "Elasticsearch")
+	c.JSON(http.StatusOK, gin.H{
+		"name":         "lograil",
+		"cluster_name": "lograil",
+		"version": gin.H{
+			"number":         elasticsearchVersion,
+			"build_flavor":   "default",
+			"lucene_version": "9.8.0",
+		},
+		"tagline": "You Know, for Search",
+	})
+}
+
+// Elasticsearch _bulk API compatibility
+func (s *Server) ingestElasticsearchBulk(c *gin.Context) {
+	started := time.Now()
+	c.Header("X-Elastic-Product", "Elasticsearch")
+
+	items, err := parser.ParseBulk(c.Request.Body, c.Param("index"), started)
+	if err != nil {
+		status := bodyErrorStatus(err)
+		c.JSON(status, gin.H{
+			"error": gin.H{
+				"type":   "illegal_argument_exception",
+				"reason": err.Error(),
+			},
+			"status": status,
+		})
+		return
+	}
+
+	// Documents over the entry limits fail on their own, as in Elasticsearch
+	project := projectID(c)
+	var logEntries []storage.LogEntry
+	for i := range items {
+		entry := items[i].Entry
+		if entry == nil {
+			continue
+		}
+		entry.ProjectID = project
+		if err := s.validateEntry(entry); err != nil {
+			items[i].Entry, items[i].Err = nil, err
+			continue
+		}
+		logEntries = append(logEntries, *entry)
+	}
+
+	if len(logEntries) > 0 {
+		if err := s.ingestValidated(c, logEntries); err != nil {
+			s.respondEnqueueError(c, err)
+			return
+		}
+	}
+
+	// Report each item the way Elasticsearch does
+	hasErrors := false
+	results := make([]gin.H, 0, len(items))
+	for _, item := range items {
+		result := gin.H{"_index": item.Index}
+		if item.ID != "" {
+			result["_id"] = item.ID
+		}
+
+		if item.Err != nil {
+			hasErrors = true
+			result["status"] = http.StatusBadRequest
+			result["error"] = gin.H{
+				"type":   "mapper_parsing_exception",
+				"reason": item.Err.Error(),
+			}
+		} else {
+			result["status"] = http.StatusCreated
+			result["result"] = "created"
+		}
+
+		results = append(results, gin.H{item.Action: result})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"took":   time.Since(started).Milliseconds(),
+		"errors": hasErrors,
+		"items":  results,
+	})
+}
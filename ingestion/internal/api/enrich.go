@@ -0,0 +1,47 @@
+package api
+
+import (
+	"time"
+
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// Reserved fields attached when metadata enrichment is enabled. Client
+// keys lose their leading underscores, so they never collide with these.
+const (
+	FieldReceivedAt = "_lograil.received_at"
+	FieldClientIP   = "_lograil.client_ip"
+	FieldUserAgent  = "_lograil.user_agent"
+	FieldAPIKeyID   = "_lograil.api_key_id"
+	FieldNodeID     = "_lograil.node_id"
+)
+
+// enrich attaches ingestion metadata to logs. c is nil for entries received
+// by the listeners, which only get the receive time and node ID.
+func (s *Server) enrich(c *gin.Context, logs []storage.LogEntry) {
+	metadata := map[string]interface{}{
+		FieldReceivedAt: time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	if s.config.NodeID != "" {
+		metadata[FieldNodeID] = s.config.NodeID
+	}
+	if c != nil {
+		metadata[FieldClientIP] = c.ClientIP()
+		if userAgent := c.Request.UserAgent(); userAgent != "" {
+			metadata[FieldUserAgent] = userAgent
+		}
+		if id := keyID(c); id != 0 {
+			metadata[FieldAPIKeyID] = id
+		}
+	}
+
+	for i := range logs {
+		if logs[i].Fields == nil {
+			logs[i].Fields = make(map[string]interface{}, len(metadata))
+		}
+		for key, value := range metadata {
+			logs[i].Fields[key] = value
+		}
+	}
+}
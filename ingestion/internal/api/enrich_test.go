@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/bizjs/Lograil/ingestion/internal/config"
+	"github.com/bizjs/Lograil/ingestion/internal/pipeline"
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+func TestProcessEnrichesMetadata(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	pl, err := pipeline.New(&pipeline.Config{
+		Tags:     map[string]string{"env": "prod"},
+		Projects: map[string]pipeline.ProjectConfig{"7": {Tags: map[string]string{"team": "payments"}}},
+	})
+	if err != nil {
+		t.Fatalf("pipeline.New failed: %v", err)
+	}
+	server, _ := newTestServerWith(t, config.Config{
+		EnrichMetadata: true,
+		NodeID:         "ingest-1",
+		TrustedProxies: []string{"10.0.0.0/8"},
+	}, pl, nil)
+
+	request := func(remoteAddr string) *gin.Context {
+		c := gin.CreateTestContextOnly(httptest.NewRecorder(), server.router)
+		c.Request = httptest.NewRequest(http.MethodPost, "/ingest/logs", nil)
+		c.Request.RemoteAddr = remoteAddr
+		c.Request.Header.Set("X-Forwarded-For", "203.0.113.9")
+		c.Request.Header.Set("User-Agent", "vector/0.40")
+		return c
+	}
+
+	logs := server.process(request("10.1.2.3:5000"), []storage.LogEntry{
+		{ProjectID: 7, Message: "a", Fields: map[string]interface{}{"env": "staging", FieldNodeID: "spoofed"}},
+	})
+	fields := logs[0].Fields
+	if fields[FieldClientIP] != "203.0.113.9" || fields[FieldUserAgent] != "vector/0.40" || fields[FieldNodeID] != "ingest-1" {
+		t.Errorf("Unexpected metadata: %v", fields)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, fields[FieldReceivedAt].(string)); err != nil {
+		t.Errorf("Invalid received_at: %v", fields[FieldReceivedAt])
+	}
+	if _, ok := fields[FieldAPIKeyID]; ok {
+		t.Errorf("Unauthenticated requests should have no API key ID: %v", fields)
+	}
+	if fields["env"] != "staging" || fields["team"] != "payments" {
+		t.Errorf("Unexpected tags: %v", fields)
+	}
+
+	logs = server.process(request("198.51.100.4:5000"), []storage.LogEntry{{ProjectID: 1, Message: "b"}})
+	if logs[0].Fields[FieldClientIP] != "198.51.100.4" {
+		t.Errorf("Forwarded header from an untrusted peer should be ignored: %v", logs[0].Fields)
+	}
+	if logs[0].Fields["env"] != "prod" || logs[0].Fields["team"] != nil {
+		t.Errorf("Unexpected tags: %v", logs[0].Fields)
+	}
+
+	logs = server.process(nil, []storage.LogEntry{{Message: "from a listener"}})
+	if _, ok := logs[0].Fields[FieldClientIP]; ok || logs[0].Fields[FieldNodeID] != "ingest-1" {
+		t.Errorf("Unexpected listener metadata: %v", logs[0].Fields)
+	}
+}
@@ -1,29 +1,56 @@
 package api
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/bizjs/Lograil/ingestion/internal/buffer"
+	"github.com/bizjs/Lograil/ingestion/internal/ratelimit"
 	"github.com/bizjs/Lograil/ingestion/internal/storage"
 	"github.com/gin-gonic/gin"
 )
 
 // Health check handler
 func (s *Server) healthCheck(c *gin.Context) {
-	// Check VictoriaLogs health
-	if err := s.victoriaLogs.HealthCheck(); err != nil {
-		c.JSON(http.StatusServiceUnavailable, gin.H{
-			"status":  "unhealthy",
-			"service": "ingestion",
-			"error":   err.Error(),
-		})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"status":  "healthy",
 		"service": "ingestion",
-	})
+		"buffer": gin.H{
+			"pending":  s.buffer.Len(),
+			"capacity": s.buffer.Capacity(),
+		},
+	}
+
+	sinks := s.sinks.Status()
+	response["sinks"] = sinks
+
+	if s.limiter != nil {
+		response["rate_limited"] = s.limiter.Throttled()
+	}
+
+	if s.pipeline != nil {
+		response["pipeline"] = s.pipeline.Stats()
+	}
+
+	if s.dedup != nil {
+		response["dedup"] = s.dedup.Stats()
+	}
+
+	// Every sink must be writable
+	for _, status := range sinks {
+		if !status.Healthy {
+			response["status"] = "unhealthy"
+			response["error"] = fmt.Sprintf("sink %s: %s", status.Name, status.Error)
+			c.JSON(http.StatusServiceUnavailable, response)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
 }
 
 // Single log ingestion handler
@@ -34,10 +61,11 @@ func (s *Server) ingestLogs(c *gin.Context) {
 		Message   string                 `json:"message" binding:"required"`
 		Source    string                 `json:"source" binding:"required"`
 		Fields    map[string]interface{} `json:"fields,omitempty"`
+		EventID   string                 `json:"event_id,omitempty"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBodyError(c, err)
 		return
 	}
 
@@ -52,37 +80,34 @@ func (s *Server) ingestLogs(c *gin.Context) {
 		Level:     req.Level,
 		Message:   req.Message,
 		Source:    req.Source,
-		Fields:    req.Fields,
+		Fields:    withEventID(req.Fields, req.EventID),
+		ProjectID: projectID(c),
 	}
 
-	// Write log to VictoriaLogs
-	if err := s.victoriaLogs.WriteLogs([]storage.LogEntry{logEntry}); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to write log",
-			"details": err.Error(),
-		})
+	// Hand the log to the background writer
+	if err := s.validateEntry(&logEntry); err != nil {
+		s.respondEnqueueError(c, err)
+		return
+	}
+	if err := s.ingestValidated(c, []storage.LogEntry{logEntry}); err != nil {
+		s.respondEnqueueError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Log ingested successfully",
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "Log accepted",
 	})
 }
 
-// Batch log ingestion handler
+// Batch log ingestion handler. Entries are validated one by one; valid
+// entries are accepted and each rejected one is reported by index.
 func (s *Server) ingestBatchLogs(c *gin.Context) {
 	var req struct {
-		Logs []struct {
-			Timestamp *time.Time             `json:"timestamp,omitempty"`
-			Level     string                 `json:"level" binding:"required"`
-			Message   string                 `json:"message" binding:"required"`
-			Source    string                 `json:"source" binding:"required"`
-			Fields    map[string]interface{} `json:"fields,omitempty"`
-		} `json:"logs" binding:"required"`
+		Logs []json.RawMessage `json:"logs" binding:"required"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBodyError(c, err)
 		return
 	}
 
@@ -92,43 +117,149 @@ func (s *Server) ingestBatchLogs(c *gin.Context) {
 	}
 
 	// Convert to LogEntry slice
-	logEntries := make([]storage.LogEntry, len(req.Logs))
-	for i, log := range req.Logs {
-		timestamp := time.Now()
-		if log.Timestamp != nil {
-			timestamp = *log.Timestamp
+	project := projectID(c)
+	logEntries := make([]storage.LogEntry, 0, len(req.Logs))
+	entryErrors := []entryError{}
+	for i, raw := range req.Logs {
+		entry, err := decodeEntry(raw, project)
+		if err == nil {
+			err = s.validateEntry(&entry)
 		}
-
-		logEntries[i] = storage.LogEntry{
-			Timestamp: timestamp,
-			Level:     log.Level,
-			Message:   log.Message,
-			Source:    log.Source,
-			Fields:    log.Fields,
+		if err != nil {
+			entryErrors = append(entryErrors, entryError{Index: i, Status: http.StatusBadRequest, Error: err.Error()})
+			continue
 		}
+		logEntries = append(logEntries, entry)
 	}
 
-	// Write logs to VictoriaLogs in batches
-	batchSize := s.config.BatchSize
-	for i := 0; i < len(logEntries); i += batchSize {
-		end := i + batchSize
-		if end > len(logEntries) {
-			end = len(logEntries)
-		}
+	if len(logEntries) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":    "No valid logs provided",
+			"accepted": 0,
+			"rejected": len(entryErrors),
+			"errors":   entryErrors,
+		})
+		return
+	}
 
-		batch := logEntries[i:end]
-		if err := s.victoriaLogs.WriteLogs(batch); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":     "Failed to write log batch",
-				"details":   err.Error(),
-				"processed": i,
-			})
-			return
-		}
+	// Hand the valid logs to the background writer; they are accepted or
+	// rejected together
+	if err := s.ingestValidated(c, logEntries); err != nil {
+		s.respondEnqueueError(c, err)
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Logs ingested successfully",
-		"count":   len(logEntries),
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":  "Logs accepted",
+		"count":    len(logEntries),
+		"accepted": len(logEntries),
+		"rejected": len(entryErrors),
+		"errors":   entryErrors,
 	})
 }
+
+// entryError reports a rejected batch entry.
+type entryError struct {
+	Index  int    `json:"index"`
+	Status int    `json:"status"`
+	Error  string `json:"error"`
+}
+
+// entryRequest is a log entry as sent to the batch and stream endpoints.
+type entryRequest struct {
+	Timestamp *time.Time             `json:"timestamp,omitempty"`
+	Level     string                 `json:"level"`
+	Message   string                 `json:"message"`
+	Source    string                 `json:"source"`
+	Fields    map[string]interface{} `json:"fields,omitempty"`
+	EventID   string                 `json:"event_id,omitempty"`
+}
+
+// decodeEntry decodes and validates a single JSON log entry.
+func decodeEntry(data []byte, project int) (storage.LogEntry, error) {
+	var req entryRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return storage.LogEntry{}, err
+	}
+
+	var missing []string
+	if req.Level == "" {
+		missing = append(missing, "level")
+	}
+	if req.Message == "" {
+		missing = append(missing, "message")
+	}
+	if req.Source == "" {
+		missing = append(missing, "source")
+	}
+	if len(missing) > 0 {
+		return storage.LogEntry{}, fmt.Errorf("missing required fields: %s", strings.Join(missing, ", "))
+	}
+
+	timestamp := time.Now()
+	if req.Timestamp != nil {
+		timestamp = *req.Timestamp
+	}
+
+	return storage.LogEntry{
+		Timestamp: timestamp,
+		Level:     req.Level,
+		Message:   req.Message,
+		Source:    req.Source,
+		Fields:    withEventID(req.Fields, req.EventID),
+		ProjectID: project,
+	}, nil
+}
+
+// withEventID stores an entry's event ID with its fields, where it is used
+// for deduplication.
+func withEventID(fields map[string]interface{}, eventID string) map[string]interface{} {
+	if eventID == "" {
+		return fields
+	}
+	if fields == nil {
+		fields = make(map[string]interface{}, 1)
+	}
+	fields[FieldEventID] = eventID
+	return fields
+}
+
+// respondEnqueueError maps ingest errors to HTTP responses
+func (s *Server) respondEnqueueError(c *gin.Context, err error) {
+	c.JSON(enqueueErrorStatus(c, err), gin.H{"error": err.Error()})
+}
+
+// enqueueErrorStatus returns the status for an ingest error and sets the
+// headers telling clients when to retry
+func enqueueErrorStatus(c *gin.Context, err error) int {
+	var limited *ratelimit.Error
+	var unknownLevel *levelError
+	var overLimit *limitError
+	switch {
+	case errors.As(err, &unknownLevel), errors.As(err, &overLimit):
+		return http.StatusBadRequest
+	case errors.As(err, &limited):
+		setRateLimitHeaders(c, limited.Decision)
+		return http.StatusTooManyRequests
+	case errors.Is(err, buffer.ErrFull):
+		c.Header("Retry-After", "1")
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusServiceUnavailable
+	}
+}
+
+// respondBodyError maps request body errors to HTTP responses
+func respondBodyError(c *gin.Context, err error) {
+	c.JSON(bodyErrorStatus(err), gin.H{"error": err.Error()})
+}
+
+// bodyErrorStatus is 413 for bodies over the decompressed size limit and
+// 400 for anything else
+func bodyErrorStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/bizjs/Lograil/ingestion/internal/dedup"
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// idempotencyKeyHeader identifies retries of the same request.
+	idempotencyKeyHeader = "Idempotency-Key"
+	// maxIdempotencyKeyLength bounds the memory held per key.
+	maxIdempotencyKeyLength = 255
+
+	// FieldEventID identifies an entry across retries.
+	FieldEventID = "event_id"
+)
+
+// idempotency replays the stored response for a request whose
+// Idempotency-Key was already seen with the same body, and rejects a reused
+// key with a different body. Responses that ask the client to retry are not
+// stored. The body is read in full, after limitBody.
+func (s *Server) idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("%s exceeds %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength),
+			})
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			respondBodyError(c, err)
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		digest := sha256.Sum256(body)
+
+		storeKey := fmt.Sprintf("request:%d:%s %s:%s", projectID(c), c.Request.Method, c.FullPath(), key)
+		result, state := s.dedup.Begin(storeKey, hex.EncodeToString(digest[:]))
+		switch state {
+		case dedup.Replay:
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(result.Status, result.ContentType, result.Body)
+			c.Abort()
+			return
+		case dedup.InProgress:
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+				"error": "A request with this Idempotency-Key is in progress",
+			})
+			return
+		case dedup.Mismatch:
+			c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+				"error": "Idempotency-Key was already used with a different request body",
+			})
+			return
+		}
+
+		writer := &captureWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				s.dedup.Abort(storeKey)
+				panic(recovered)
+			}
+		}()
+
+		c.Next()
+
+		status := writer.Status()
+		if status == http.StatusTooManyRequests || status == http.StatusRequestTimeout || status >= 500 {
+			s.dedup.Abort(storeKey)
+			return
+		}
+		s.dedup.Complete(storeKey, dedup.Result{
+			Status:      status,
+			ContentType: writer.Header().Get("Content-Type"),
+			Body:        writer.body.Bytes(),
+		})
+	}
+}
+
+// captureWriter keeps a copy of the response body.
+type captureWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *captureWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *captureWriter) WriteString(data string) (int, error) {
+	w.body.WriteString(data)
+	return w.ResponseWriter.WriteString(data)
+}
+
+// claimEvents drops entries whose event ID was already ingested within the
+// dedup window and claims the IDs of the kept entries.
+func (s *Server) claimEvents(logs []storage.LogEntry) []storage.LogEntry {
+	if s.dedup == nil {
+		return logs
+	}
+
+	kept := logs[:0]
+	for _, entry := range logs {
+		if key, ok := eventKey(entry); ok && !s.dedup.Claim(key) {
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	return kept
+}
+
+// releaseEvents forgets the event IDs of claimed entries that could not be
+// enqueued, so that retries are not mistaken for duplicates.
+func (s *Server) releaseEvents(logs []storage.LogEntry) {
+	if s.dedup == nil {
+		return
+	}
+	for _, entry := range logs {
+		if key, ok := eventKey(entry); ok {
+			s.dedup.Release(key)
+		}
+	}
+}
+
+// eventKey returns the dedup key of an entry with an event ID.
+func eventKey(entry storage.LogEntry) (string, bool) {
+	id, ok := entry.Fields[FieldEventID].(string)
+	if !ok || id == "" {
+		return "", false
+	}
+	return fmt.Sprintf("event:%d:%s", entry.ProjectID, id), true
+}
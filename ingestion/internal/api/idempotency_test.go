@@ -0,0 +1,79 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bizjs/Lograil/ingestion/internal/config"
+	"github.com/bizjs/Lograil/ingestion/internal/dedup"
+	"github.com/gin-gonic/gin"
+)
+
+func TestIdempotentIngestion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	server, _ := newTestServer(t, config.Config{
+		BufferSize:  4,
+		DedupWindow: time.Minute,
+	})
+
+	post := func(key, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/ingest/batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set(idempotencyKeyHeader, key)
+		}
+		server.router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	body := `{"logs":[{"level":"info","message":"a","source":"s"},{"level":"info","source":"s"}]}`
+	first := post("batch-1", body)
+	replay := post("batch-1", body)
+	if first.Code != http.StatusAccepted || replay.Code != first.Code || replay.Body.String() != first.Body.String() {
+		t.Fatalf("Expected the original response to be replayed, got %d %s and %d %s", first.Code, first.Body, replay.Code, replay.Body)
+	}
+	if replay.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("Expected replay header, got %v", replay.Header())
+	}
+	if code := post("batch-1", strings.Replace(body, `"a"`, `"z"`, 1)).Code; code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for a reused key with a different body, got %d", code)
+	}
+	if server.buffer.Len() != 1 {
+		t.Errorf("Replay should not be buffered again, got %d pending", server.buffer.Len())
+	}
+
+	events := `{"logs":[
+		{"level":"info","message":"b","source":"s","event_id":"e1"},
+		{"level":"info","message":"b","source":"s","event_id":"e1"},
+		{"level":"info","message":"c","source":"s","fields":{"event_id":"e2"}}
+	]}`
+	if code := post("", events).Code; code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d", code)
+	}
+	if code := post("", events).Code; code != http.StatusAccepted {
+		t.Fatalf("Expected 202 for duplicate events, got %d", code)
+	}
+	if server.buffer.Len() != 3 {
+		t.Errorf("Expected duplicate events to be dropped, got %d pending", server.buffer.Len())
+	}
+	if stats := server.dedup.Stats(); stats.Duplicates != 4 || stats.Replays != 1 {
+		t.Errorf("Unexpected dedup stats: %+v", stats)
+	}
+
+	// A failed enqueue is neither stored for replay nor counted as ingested
+	full := `{"logs":[{"level":"info","message":"d","source":"s","event_id":"e3"},{"level":"info","message":"e","source":"s"}]}`
+	if code := post("batch-2", full).Code; code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 from the full buffer, got %d", code)
+	}
+	if _, state := server.dedup.Begin("request:0:POST /ingest/batch:batch-2", ""); state != dedup.Started {
+		t.Errorf("Failed request should not be stored, got state %v", state)
+	}
+	if !server.dedup.Claim("event:0:e3") {
+		t.Error("Event of a failed request should be released")
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/bizjs/Lograil/ingestion/internal/parser"
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+)
+
+// FieldOriginalLevel keeps the level as sent when it is normalised.
+const FieldOriginalLevel = "original_level"
+
+// levelError is returned in strict mode for entries with an unknown level.
+type levelError struct {
+	level string
+}
+
+func (e *levelError) Error() string {
+	return fmt.Sprintf("unknown level %q", e.level)
+}
+
+// validLevel reports whether the level of an entry from source is
+// accepted: any level outside strict mode, only known ones in it.
+func (s *Server) validLevel(level, source string) bool {
+	if !s.config.StrictLevels {
+		return true
+	}
+	_, ok := parser.NormalizeLevel(level, s.levelScheme(source))
+	return ok
+}
+
+// levelScheme returns the numeric level scheme configured for source, or
+// none to read only the numbers no two schemes share.
+func (s *Server) levelScheme(source string) parser.LevelScheme {
+	for _, rule := range s.config.LevelSchemes {
+		if ok, _ := path.Match(rule.Sources, source); ok {
+			return rule.Scheme
+		}
+	}
+	return ""
+}
+
+// normalizeLevels replaces known levels with their canonical form, keeping
+// the original in FieldOriginalLevel. Unknown levels are left as they are.
+func (s *Server) normalizeLevels(logs []storage.LogEntry) {
+	if !s.config.NormalizeLevels {
+		return
+	}
+	for i := range logs {
+		level, ok := parser.NormalizeLevel(logs[i].Level, s.levelScheme(logs[i].Source))
+		if !ok || level == logs[i].Level {
+			continue
+		}
+		if logs[i].Fields == nil {
+			logs[i].Fields = make(map[string]interface{}, 1)
+		}
+		if _, exists := logs[i].Fields[FieldOriginalLevel]; !exists {
+			logs[i].Fields[FieldOriginalLevel] = logs[i].Level
+		}
+		logs[i].Level = level
+	}
+}
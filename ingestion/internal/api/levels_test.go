@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bizjs/Lograil/ingestion/internal/config"
+	"github.com/bizjs/Lograil/ingestion/internal/parser"
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+func TestLevelNormalisation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	server, _ := newTestServer(t, config.Config{
+		NormalizeLevels: true,
+		StrictLevels:    true,
+		LevelSchemes:    []config.LevelSchemeRule{{Sources: "otel-*", Scheme: parser.SchemeOTel}},
+	})
+
+	logs := server.process(nil, []storage.LogEntry{
+		{Level: "WARNING", Message: "a"},
+		{Level: "info", Message: "b"},
+		{Level: "custom", Message: "c"},
+		{Level: "20", Message: "d", Source: "otel-collector"},
+		{Level: "20", Message: "e", Source: "app"},
+	})
+	if logs[0].Level != "warn" || logs[0].Fields[FieldOriginalLevel] != "WARNING" {
+		t.Errorf("Unexpected normalised entry: %+v", logs[0])
+	}
+	if logs[1].Level != "info" || logs[1].Fields != nil {
+		t.Errorf("Canonical levels should be left alone: %+v", logs[1])
+	}
+	if logs[2].Level != "custom" {
+		t.Errorf("Unknown levels should be kept: %+v", logs[2])
+	}
+	if logs[3].Level != "error" || logs[4].Level != "20" {
+		t.Errorf("Expected 20 read as OpenTelemetry only where configured: %+v %+v", logs[3], logs[4])
+	}
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		server.router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	single := post("/ingest/logs", `{"level":"loud","message":"a","source":"s"}`)
+	if single.Code != http.StatusBadRequest || !strings.Contains(single.Body.String(), `unknown level \"loud\"`) {
+		t.Errorf("Expected 400 for unknown level, got %d: %s", single.Code, single.Body)
+	}
+	if server.buffer.Len() != 0 {
+		t.Errorf("Rejected entry should not be buffered, got %d pending", server.buffer.Len())
+	}
+
+	batch := post("/ingest/batch", `{"logs":[{"level":"30","message":"a","source":"s"},{"level":"loud","message":"b","source":"s"}]}`)
+	if batch.Code != http.StatusAccepted || !strings.Contains(batch.Body.String(), `{"index":1,"status":400,"error":"unknown level \"loud\""}`) {
+		t.Errorf("Expected the batch entry with an unknown level to be rejected, got %d: %s", batch.Code, batch.Body)
+	}
+
+	stream := post("/ingest/stream", "{\"level\":\"E\",\"message\":\"a\",\"source\":\"s\"}\n{\"level\":\"loud\",\"message\":\"b\",\"source\":\"s\"}")
+	if stream.Code != http.StatusAccepted || !strings.Contains(stream.Body.String(), `"rejected":1`) {
+		t.Errorf("Expected the stream line with an unknown level to be rejected, got %d: %s", stream.Code, stream.Body)
+	}
+}
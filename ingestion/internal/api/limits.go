@@ -0,0 +1,294 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/bizjs/Lograil/ingestion/internal/pipeline"
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// FieldTruncated marks entries whose message or field values were cut to
+// the configured limits.
+const FieldTruncated = "_truncated"
+
+// limitError is returned for entries over the field limits.
+type limitError struct {
+	reason string
+}
+
+func (e *limitError) Error() string {
+	return e.reason
+}
+
+// limitBody caps the request body as received, before any decoding.
+func limitBody(maxSize int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.ContentLength > maxSize {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": fmt.Sprintf("request body exceeds %d bytes", maxSize),
+			})
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize)
+		c.Next()
+	}
+}
+
+// validateEntries validates each entry, returning the valid ones, the
+// number rejected and the first error.
+func (s *Server) validateEntries(logs []storage.LogEntry) ([]storage.LogEntry, int, error) {
+	valid := logs[:0]
+	rejected := 0
+	var first error
+	for i := range logs {
+		if err := s.validateEntry(&logs[i]); err != nil {
+			if first == nil {
+				first = err
+			}
+			rejected++
+			continue
+		}
+		valid = append(valid, logs[i])
+	}
+	return valid, rejected, first
+}
+
+// validateEntry checks the level in strict mode, truncates oversized
+// messages and field values, and sanitises field keys. Entries with too
+// many fields, too deep nesting or too long keys are rejected.
+func (s *Server) validateEntry(entry *storage.LogEntry) error {
+	if !s.validLevel(entry.Level, entry.Source) {
+		return &levelError{level: entry.Level}
+	}
+
+	truncated := false
+	if max := s.config.MaxMessageSize; max > 0 && len(entry.Message) > max {
+		entry.Message = truncateString(entry.Message, max)
+		truncated = true
+	}
+
+	if entry.Fields != nil {
+		count := 0
+		fields, cut, err := s.sanitizeFields(entry.Fields, 1, &count)
+		if err != nil {
+			return err
+		}
+		entry.Fields = fields
+		truncated = truncated || cut
+	}
+
+	if truncated {
+		if entry.Fields == nil {
+			entry.Fields = make(map[string]interface{}, 1)
+		}
+		entry.Fields[FieldTruncated] = true
+	}
+	return nil
+}
+
+// limitFields applies the field limits again after the pipeline, whose
+// processors may extract fields from the message. An entry that passed
+// validation is not rejected for them: fields over the limits are dropped
+// instead, and the entry is marked truncated. Fields set by the ingestion
+// service itself are kept as they are.
+func (s *Server) limitFields(entry *storage.LogEntry) {
+	if entry.Fields == nil {
+		return
+	}
+
+	fields := make(map[string]interface{}, len(entry.Fields))
+	for _, key := range []string{FieldTruncated, FieldEventID, FieldOriginalLevel, pipeline.SampleRateField} {
+		if value, ok := entry.Fields[key]; ok {
+			fields[key] = value
+			delete(entry.Fields, key)
+		}
+	}
+
+	count := 0
+	truncated := false
+	for _, key := range fieldOrder(entry.Fields) {
+		before := count
+		cut, err := s.sanitizeField(fields, key, entry.Fields[key], 1, &count)
+		if err != nil {
+			count = before
+			truncated = true
+			continue
+		}
+		truncated = truncated || cut
+	}
+	if truncated {
+		fields[FieldTruncated] = true
+	}
+	entry.Fields = fields
+}
+
+// sanitizeFields returns a copy of fields with sanitised keys and
+// truncated string values. count accumulates keys across nesting levels.
+func (s *Server) sanitizeFields(fields map[string]interface{}, depth int, count *int) (map[string]interface{}, bool, error) {
+	if err := s.checkDepth(depth); err != nil {
+		return nil, false, err
+	}
+
+	sanitized := make(map[string]interface{}, len(fields))
+	truncated := false
+	for _, key := range fieldOrder(fields) {
+		cut, err := s.sanitizeField(sanitized, key, fields[key], depth, count)
+		if err != nil {
+			return nil, false, err
+		}
+		truncated = truncated || cut
+	}
+	return sanitized, truncated, nil
+}
+
+// sanitizeField adds a field to sanitized under its sanitised key. A dotted
+// key is stored as the nested objects VictoriaLogs flattens it back to, so
+// that it is limited and merged like them.
+func (s *Server) sanitizeField(sanitized map[string]interface{}, key string, value interface{}, depth int, count *int) (bool, error) {
+	*count++
+	if max := s.config.MaxFields; max > 0 && *count > max {
+		return false, &limitError{reason: fmt.Sprintf("more than %d fields", max)}
+	}
+
+	path := sanitizeKey(key)
+	if len(path) == 0 {
+		return false, nil
+	}
+	if max := s.config.MaxFieldKeyLength; max > 0 && len(strings.Join(path, ".")) > max {
+		return false, &limitError{reason: fmt.Sprintf("field key %q exceeds %d bytes", truncateString(strings.Join(path, "."), 32)+"...", max)}
+	}
+
+	// Each dot nests the value one level deeper
+	depth += len(path) - 1
+	if err := s.checkDepth(depth); err != nil {
+		return false, err
+	}
+	value, truncated, err := s.sanitizeValue(value, depth, count)
+	if err != nil {
+		return false, err
+	}
+	setField(sanitized, path, value)
+	return truncated, nil
+}
+
+func (s *Server) sanitizeValue(value interface{}, depth int, count *int) (interface{}, bool, error) {
+	switch v := value.(type) {
+	case string:
+		if max := s.config.MaxFieldValueSize; max > 0 && len(v) > max {
+			return truncateString(v, max), true, nil
+		}
+	case map[string]interface{}:
+		return s.sanitizeFields(v, depth+1, count)
+	case []interface{}:
+		// Each array is a nesting level of its own
+		if err := s.checkDepth(depth + 1); err != nil {
+			return nil, false, err
+		}
+		truncated := false
+		for i := range v {
+			element, cut, err := s.sanitizeValue(v[i], depth+1, count)
+			if err != nil {
+				return nil, false, err
+			}
+			v[i] = element
+			truncated = truncated || cut
+		}
+		return v, truncated, nil
+	}
+	return value, false, nil
+}
+
+// checkDepth rejects fields nested deeper than MaxFieldDepth.
+func (s *Server) checkDepth(depth int) error {
+	if max := s.config.MaxFieldDepth; max > 0 && depth > max {
+		return &limitError{reason: fmt.Sprintf("fields nested deeper than %d levels", max)}
+	}
+	return nil
+}
+
+// sanitizeKey splits a key at its dots and drops control characters and
+// invalid UTF-8 from each part, as well as leading underscores, which are
+// reserved for internal fields such as _msg and _time. Empty parts are
+// dropped.
+func sanitizeKey(key string) []string {
+	var path []string
+	for _, part := range strings.Split(strings.ToValidUTF8(key, ""), ".") {
+		var b strings.Builder
+		for _, r := range part {
+			if !unicode.IsControl(r) {
+				b.WriteRune(r)
+			}
+		}
+		if clean := strings.TrimLeft(b.String(), "_"); clean != "" {
+			path = append(path, clean)
+		}
+	}
+	return path
+}
+
+// fieldOrder returns the keys of fields that need no sanitising, followed
+// by the others, each sorted, so that collisions resolve the same way on
+// every request.
+func fieldOrder(fields map[string]interface{}) []string {
+	var clean, others []string
+	for key := range fields {
+		if path := sanitizeKey(key); len(path) == 1 && path[0] == key {
+			clean = append(clean, key)
+		} else {
+			others = append(others, key)
+		}
+	}
+	sort.Strings(clean)
+	sort.Strings(others)
+	return append(clean, others...)
+}
+
+// setField stores value under path, creating objects along it. Where the
+// flattened name of a field is already taken, the earlier field is kept
+// and objects are merged, so a sanitised key never replaces a key that was
+// already clean.
+func setField(fields map[string]interface{}, path []string, value interface{}) {
+	for _, name := range path[:len(path)-1] {
+		next, ok := fields[name].(map[string]interface{})
+		if !ok {
+			if _, exists := fields[name]; exists {
+				return
+			}
+			next = make(map[string]interface{})
+			fields[name] = next
+		}
+		fields = next
+	}
+
+	name := path[len(path)-1]
+	existing, exists := fields[name]
+	if !exists {
+		fields[name] = value
+		return
+	}
+	target, ok := existing.(map[string]interface{})
+	source, isMap := value.(map[string]interface{})
+	if ok && isMap {
+		for key, nested := range source {
+			setField(target, []string{key}, nested)
+		}
+	}
+}
+
+// truncateString cuts s to at most max bytes without splitting a UTF-8
+// sequence.
+func truncateString(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
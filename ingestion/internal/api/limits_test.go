@@ -0,0 +1,214 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bizjs/Lograil/ingestion/internal/config"
+	"github.com/bizjs/Lograil/ingestion/internal/pipeline"
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+func TestEntryLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	server, _ := newTestServer(t, config.Config{
+		BatchSize:         100,
+		BufferSize:        100,
+		MaxBodySize:       1024,
+		MaxMessageSize:    8,
+		MaxFieldValueSize: 4,
+		MaxFields:         4,
+		MaxFieldDepth:     2,
+		MaxFieldKeyLength: 10,
+	})
+
+	entry := storage.LogEntry{
+		Level:   "info",
+		Message: "héééééé",
+		Fields: map[string]interface{}{
+			"http.path": "/a",
+			"_time":     "x",
+			"ok\x00":    "abcdefgh",
+		},
+	}
+	if err := server.validateEntry(&entry); err != nil {
+		t.Fatalf("validateEntry failed: %v", err)
+	}
+	if entry.Message != "hééé" || entry.Fields[FieldTruncated] != true {
+		t.Errorf("Expected message truncated at a rune boundary: %q %v", entry.Message, entry.Fields)
+	}
+	nested, _ := entry.Fields["http"].(map[string]interface{})
+	if nested["path"] != "/a" || entry.Fields["time"] != "x" || entry.Fields["ok"] != "abcd" {
+		t.Errorf("Unexpected sanitised fields: %v", entry.Fields)
+	}
+
+	// Keys whose flattened names collide keep the clean one, and client
+	// fields never reach the reserved _lograil prefix
+	collision := storage.LogEntry{
+		Level:   "info",
+		Message: "m",
+		Fields: map[string]interface{}{
+			"a.b":         1,
+			"a":           map[string]interface{}{"b": 2},
+			"_lograil.ip": "x",
+		},
+	}
+	if err := server.validateEntry(&collision); err != nil {
+		t.Fatalf("validateEntry failed: %v", err)
+	}
+	a, _ := collision.Fields["a"].(map[string]interface{})
+	if len(a) != 1 || a["b"] != 2 || collision.Fields["lograil"] == nil || collision.Fields["_lograil"] != nil {
+		t.Errorf("Unexpected fields after collisions: %v", collision.Fields)
+	}
+
+	rejected := []map[string]interface{}{
+		{"a": 1, "b": 2, "c": 3, "d": 4, "e": 5},
+		{"a": map[string]interface{}{"b": map[string]interface{}{"c": 1}}},
+		{"a": []interface{}{[]interface{}{[]interface{}{1}}}},
+		{"a_very_long_key": 1},
+	}
+	for _, fields := range rejected {
+		if err := server.validateEntry(&storage.LogEntry{Level: "info", Message: "m", Fields: fields}); err == nil {
+			t.Errorf("Expected fields to be rejected: %v", fields)
+		}
+	}
+
+	// Listener entries have no response, so invalid ones are dropped
+	listener := []storage.LogEntry{
+		{Level: "info", Message: "truncated message", Source: "syslog"},
+		{Level: "info", Message: "m", Source: "syslog", Fields: map[string]interface{}{"a": 1, "b": 2, "c": 3, "d": 4, "e": 5}},
+	}
+	if err := server.Ingest(listener); err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+	if server.buffer.Len() != 1 || listener[0].Message != "truncate" {
+		t.Errorf("Expected one truncated listener entry, got %d pending: %q", server.buffer.Len(), listener[0].Message)
+	}
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		server.router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	single := post("/ingest/logs", `{"level":"info","message":"m","source":"s","fields":{"a":{"b":{"c":1}}}}`)
+	if single.Code != http.StatusBadRequest || !strings.Contains(single.Body.String(), "nested deeper than 2 levels") {
+		t.Errorf("Expected 400 for nested fields, got %d: %s", single.Code, single.Body)
+	}
+
+	large := post("/ingest/logs", `{"level":"info","message":"`+strings.Repeat("x", 2048)+`","source":"s"}`)
+	if large.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for a body over MaxBodySize, got %d: %s", large.Code, large.Body)
+	}
+
+	stream := post("/ingest/stream", strings.Repeat(`{"level":"info","message":"m","source":"s"}`+"\n", 40))
+	if stream.Code != http.StatusAccepted {
+		t.Errorf("Streams should not be capped by MaxBodySize, got %d: %s", stream.Code, stream.Body)
+	}
+}
+
+func TestExtractedFieldLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	pl, err := pipeline.New(&pipeline.Config{
+		Pipelines: []pipeline.PipelineConfig{
+			{Name: "json", Sources: []string{"*"}, Processors: []pipeline.ProcessorConfig{{Type: "json"}}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("pipeline.New failed: %v", err)
+	}
+	server, _ := newTestServerWith(t, config.Config{
+		MaxFields: 2,
+	}, pl, nil)
+
+	entry := storage.LogEntry{
+		Level:   "info",
+		Message: `{"a":1,"b":2,"c":3,"_stream":"x","\u0001ctl":1}`,
+		Source:  "app",
+	}
+	if err := server.validateEntry(&entry); err != nil {
+		t.Fatalf("validateEntry failed: %v", err)
+	}
+	logs := server.process(nil, []storage.LogEntry{entry})
+
+	// Clean keys are kept first, up to the limit
+	fields := logs[0].Fields
+	if len(fields) != 3 || fmt.Sprint(fields["a"]) != "1" || fmt.Sprint(fields["b"]) != "2" || fields[FieldTruncated] != true {
+		t.Errorf("Expected extracted fields cut to the limit, got %v", fields)
+	}
+}
+
+func TestPartialAcceptance(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	server, out := newTestServer(t, config.Config{
+		MaxFields: 2,
+	})
+
+	post := func(path, contentType, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		server.router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	bulk := post("/app/_bulk", "application/x-ndjson", `{"index":{}}
+{"message":"ok"}
+{"index":{}}
+{"message":"too many fields","a":1,"b":2,"c":3}
+`)
+	var bulkResponse struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int `json:"status"`
+			Error  struct {
+				Type string `json:"type"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(bulk.Body.Bytes(), &bulkResponse); err != nil {
+		t.Fatalf("Invalid response: %v", err)
+	}
+	if bulk.Code != http.StatusOK || !bulkResponse.Errors || len(bulkResponse.Items) != 2 ||
+		bulkResponse.Items[0]["index"].Status != http.StatusCreated ||
+		bulkResponse.Items[1]["index"].Error.Type != "mapper_parsing_exception" {
+		t.Errorf("Expected the second document alone to fail, got %d: %s", bulk.Code, bulk.Body.String())
+	}
+
+	loki := post("/loki/api/v1/push", "application/json", `{"streams":[
+		{"stream":{"job":"a"},"values":[["1700000000000000000","ok"]]},
+		{"stream":{"job":"b","x":"1","y":"2"},"values":[["1700000000000000000","too many fields"]]}
+	]}`)
+	if loki.Code != http.StatusBadRequest || !strings.Contains(loki.Body.String(), "1 of 2 entries rejected") {
+		t.Errorf("Expected a 400 naming the rejected entry, got %d: %s", loki.Code, loki.Body.String())
+	}
+
+	otlp := post("/v1/logs", "application/json", `{"resourceLogs":[{"scopeLogs":[{"logRecords":[
+		{"body":{"stringValue":"ok"}},
+		{"body":{"stringValue":"too many fields"},"attributes":[
+			{"key":"a","value":{"intValue":"1"}},
+			{"key":"b","value":{"intValue":"2"}},
+			{"key":"c","value":{"intValue":"3"}}
+		]}
+	]}]}]}`)
+	if otlp.Code != http.StatusOK || !strings.Contains(otlp.Body.String(), `"rejectedLogRecords":"1"`) {
+		t.Errorf("Expected a partial success rejecting one record, got %d: %s", otlp.Code, otlp.Body.String())
+	}
+
+	if err := server.Shutdown(); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if out.count() != 3 {
+		t.Errorf("Expected the 3 valid entries to be written, got %d", out.count())
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/bizjs/Lograil/ingestion/internal/parser"
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// Loki push API, accepting JSON or snappy-compressed protobuf
+func (s *Server) ingestLokiPush(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		respondBodyError(c, err)
+		return
+	}
+
+	// Promtail and Alloy send protobuf; anything but JSON is treated as such
+	mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+	var logEntries []storage.LogEntry
+	if mediaType == "application/json" {
+		logEntries, err = parser.DecodeLokiJSON(body)
+	} else {
+		logEntries, err = parser.DecodeLokiProtobuf(body, int(s.config.MaxDecompressedSize))
+	}
+	if errors.Is(err, parser.ErrTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	project := projectID(c)
+	for i := range logEntries {
+		logEntries[i].ProjectID = project
+	}
+	total := len(logEntries)
+	logEntries, rejected, err := s.validateEntries(logEntries)
+
+	if len(logEntries) > 0 {
+		if err := s.ingestValidated(c, logEntries); err != nil {
+			s.respondEnqueueError(c, err)
+			return
+		}
+	}
+
+	// Like Loki, keep the valid entries and report the others with a 400,
+	// which clients do not retry
+	if rejected > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("%d of %d entries rejected: %v", rejected, total, err),
+		})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
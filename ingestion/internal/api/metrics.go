@@ -0,0 +1,70 @@
+package api
+
+import (
+	"errors"
+	"time"
+
+	"github.com/bizjs/Lograil/ingestion/internal/buffer"
+	"github.com/bizjs/Lograil/ingestion/internal/metrics"
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// unmatchedRoute labels requests that matched no route, so that arbitrary
+// paths do not become series.
+const unmatchedRoute = "unmatched"
+
+// observeRequests records the count and latency of every request by route.
+func (s *Server) observeRequests() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		s.metrics.ObserveRequest(route, c.Request.Method, c.Writer.Status(), time.Since(start))
+	}
+}
+
+// watchState exports the buffer, sink and drop counters on collection.
+func (s *Server) watchState() {
+	state := metrics.State{
+		BufferDepth:    s.buffer.Len,
+		BufferCapacity: s.buffer.Capacity,
+		Sinks:          s.sinks.Stats,
+	}
+	if s.pipeline != nil {
+		state.Dropped = func() int64 { return s.pipeline.Stats().Dropped }
+	}
+	if s.dedup != nil {
+		state.Duplicates = func() int64 { return s.dedup.Stats().Duplicates }
+	}
+	if s.limiter != nil {
+		state.Throttled = s.limiter.Throttled
+	}
+	s.metrics.Watch(state)
+}
+
+// accepted counts logs handed to the buffer.
+func (s *Server) accepted(logs []storage.LogEntry) {
+	if s.metrics != nil {
+		s.metrics.ObserveAccepted(logs)
+	}
+}
+
+// rejected counts logs the buffer refused with err.
+func (s *Server) rejected(logs []storage.LogEntry, err error) {
+	if s.metrics == nil {
+		return
+	}
+	reason := "error"
+	switch {
+	case errors.Is(err, buffer.ErrFull):
+		reason = "full"
+	case errors.Is(err, buffer.ErrClosed):
+		reason = "closed"
+	}
+	s.metrics.ObserveRejected(reason, len(logs))
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/bizjs/Lograil/ingestion/internal/config"
+	"github.com/bizjs/Lograil/ingestion/internal/metrics"
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	server, _ := newTestServerWith(t, config.Config{}, nil, metrics.New())
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		server.router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	if code := serve(http.MethodPost, "/ingest/logs", `{"level":"info","message":"m","source":"web"}`).Code; code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d", code)
+	}
+	serve(http.MethodGet, "/no/such/path", "")
+	if err := server.Ingest(make([]storage.LogEntry, 10)); err == nil {
+		t.Fatal("Expected a batch to be rejected by the full buffer")
+	}
+
+	recorder := serve(http.MethodGet, "/metrics", "")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected 200 from /metrics, got %d", recorder.Code)
+	}
+	body := recorder.Body.String()
+	for _, want := range []string{
+		`lograil_ingestion_http_requests_total{method="POST",route="/ingest/logs",status="202"} 1`,
+		`lograil_ingestion_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`lograil_ingestion_entries_accepted_total{project="0",source="web"} 1`,
+		`lograil_ingestion_entries_rejected_total{reason="full"} 10`,
+		`lograil_ingestion_buffer_entries 1`,
+		`lograil_ingestion_buffer_capacity_entries 10`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in metrics output:\n%s", want, body)
+		}
+	}
+}
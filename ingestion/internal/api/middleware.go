@@ -0,0 +1,126 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/bizjs/Lograil/ingestion/internal/auth"
+	"github.com/bizjs/Lograil/ingestion/internal/ratelimit"
+	"github.com/bizjs/Lograil/pkg/apikey"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	principalKey   = "principal"
+	bodyCounterKey = "body_counter"
+)
+
+// apiKeyAuth requires a valid API key with write permission, sent either as
+// "Authorization: Bearer <key>" or "X-API-Key: <key>".
+func (s *Server) apiKeyAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rawKey := apikey.FromHeader(c.Request.Header)
+		if rawKey == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "API key required"})
+			return
+		}
+
+		principal, err := s.keys.Authenticate(c.Request.Context(), rawKey)
+		switch {
+		case err == nil:
+			c.Set(principalKey, principal)
+			c.Next()
+		case errors.Is(err, auth.ErrForbidden), errors.Is(err, auth.ErrInactiveProject):
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, auth.ErrInvalidKey), errors.Is(err, auth.ErrInactiveKey), errors.Is(err, auth.ErrExpiredKey):
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			log.Printf("API key verification failed: %v", err)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify API key"})
+		}
+	}
+}
+
+// principal returns the authenticated principal, or nil when
+// authentication is disabled.
+func principal(c *gin.Context) *auth.Principal {
+	if value, ok := c.Get(principalKey); ok {
+		return value.(*auth.Principal)
+	}
+	return nil
+}
+
+// projectID returns the authenticated project, or 0 when authentication is
+// disabled.
+func projectID(c *gin.Context) int {
+	if p := principal(c); p != nil {
+		return p.ProjectID
+	}
+	return 0
+}
+
+// keyID returns the authenticated API key, or 0 when authentication is
+// disabled.
+func keyID(c *gin.Context) int {
+	if p := principal(c); p != nil {
+		return p.KeyID
+	}
+	return 0
+}
+
+// rateLimit rejects callers whose buckets are already exhausted before the
+// body is read, and counts body bytes for the checks in ingestValidated.
+func (s *Server) rateLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		decision := s.limiter.Allow(ratelimit.Request{KeyID: keyID(c), ProjectID: projectID(c)})
+		if !decision.Allowed {
+			err := &ratelimit.Error{Decision: decision}
+			setRateLimitHeaders(c, decision)
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+			return
+		}
+
+		counter := &countingBody{ReadCloser: c.Request.Body}
+		c.Request.Body = counter
+		c.Set(bodyCounterKey, counter)
+		c.Next()
+	}
+}
+
+// setRateLimitHeaders describes the most constraining bucket of a decision
+func setRateLimitHeaders(c *gin.Context, decision ratelimit.Decision) {
+	if decision.Scope == "" {
+		return
+	}
+	c.Header("X-RateLimit-Limit", strconv.FormatFloat(decision.Limit, 'f', -1, 64))
+	c.Header("X-RateLimit-Remaining", strconv.FormatInt(int64(decision.Remaining), 10))
+	c.Header("X-RateLimit-Reset", strconv.FormatInt(int64(math.Ceil(decision.Reset.Seconds())), 10))
+	c.Header("X-RateLimit-Scope", decision.Scope+"-"+decision.Dimension)
+	if !decision.Allowed {
+		c.Header("Retry-After", strconv.FormatInt(max(1, int64(math.Ceil(decision.RetryAfter.Seconds()))), 10))
+	}
+}
+
+// countingBody counts the bytes read from a request body.
+type countingBody struct {
+	io.ReadCloser
+	read  int64
+	taken int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	return n, err
+}
+
+// take returns the bytes read since the previous call.
+func (b *countingBody) take() int {
+	n := b.read - b.taken
+	b.taken = b.read
+	return int(n)
+}
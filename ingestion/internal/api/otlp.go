@@ -0,0 +1,67 @@
+package api
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/bizjs/Lograil/ingestion/internal/parser"
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// OTLP/HTTP logs receiver accepting protobuf and JSON encodings
+func (s *Server) ingestOTLPLogs(c *gin.Context) {
+	mediaType, _, _ := mime.ParseMediaType(c.ContentType())
+
+	var decode func([]byte, time.Time) ([]storage.LogEntry, error)
+	switch mediaType {
+	case "application/x-protobuf":
+		decode = parser.DecodeOTLPProtobuf
+	case "application/json":
+		decode = parser.DecodeOTLPJSON
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error": "Content-Type must be application/x-protobuf or application/json",
+		})
+		return
+	}
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		respondBodyError(c, err)
+		return
+	}
+
+	logEntries, err := decode(body, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	project := projectID(c)
+	for i := range logEntries {
+		logEntries[i].ProjectID = project
+	}
+	logEntries, rejected, err := s.validateEntries(logEntries)
+
+	if len(logEntries) > 0 {
+		if err := s.ingestValidated(c, logEntries); err != nil {
+			s.respondEnqueueError(c, err)
+			return
+		}
+	}
+
+	// Records over the entry limits are reported in partial_success; an
+	// empty response signals full success
+	message := ""
+	if err != nil {
+		message = err.Error()
+	}
+	if mediaType == "application/x-protobuf" {
+		c.Data(http.StatusOK, "application/x-protobuf", parser.EncodeOTLPResponseProtobuf(rejected, message))
+		return
+	}
+	c.Data(http.StatusOK, "application/json", parser.EncodeOTLPResponseJSON(rejected, message))
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bizjs/Lograil/ingestion/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+func TestIngestRateLimited(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	server, _ := newTestServer(t, config.Config{
+		RateLimitProjectEvents: 2,
+		RateLimitBurst:         time.Second,
+	})
+
+	body := `{"logs":[{"level":"info","message":"a","source":"s"},{"level":"info","message":"b","source":"s"}]}`
+	post := func() *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/ingest/batch", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		server.router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	first := post()
+	if first.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d: %s", first.Code, first.Body)
+	}
+	if first.Header().Get("X-RateLimit-Limit") != "2" || first.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("Unexpected rate limit headers: %v", first.Header())
+	}
+
+	second := post()
+	if second.Code != http.StatusTooManyRequests {
+		t.Fatalf("Expected 429, got %d: %s", second.Code, second.Body)
+	}
+	if second.Header().Get("Retry-After") != "1" || second.Header().Get("X-RateLimit-Scope") != "project-events" {
+		t.Errorf("Unexpected rate limit headers: %v", second.Header())
+	}
+
+	if throttled := server.limiter.Throttled()["project"]; throttled.Requests != 1 {
+		t.Errorf("Expected 1 throttled request, got %+v", throttled)
+	}
+}
@@ -2,43 +2,89 @@ package api
 
 import (
 	"context"
+	"log"
 	"net/http"
 	"time"
 
+	"github.com/bizjs/Lograil/ingestion/internal/auth"
+	"github.com/bizjs/Lograil/ingestion/internal/buffer"
 	"github.com/bizjs/Lograil/ingestion/internal/config"
+	"github.com/bizjs/Lograil/ingestion/internal/dedup"
+	"github.com/bizjs/Lograil/ingestion/internal/metrics"
+	"github.com/bizjs/Lograil/ingestion/internal/pipeline"
+	"github.com/bizjs/Lograil/ingestion/internal/ratelimit"
+	"github.com/bizjs/Lograil/ingestion/internal/sink"
 	"github.com/bizjs/Lograil/ingestion/internal/storage"
 	"github.com/gin-gonic/gin"
 )
 
 type Server struct {
-	router       *gin.Engine
-	server       *http.Server
-	config       *config.Config
-	victoriaLogs *storage.VictoriaLogsClient
+	router   *gin.Engine
+	server   *http.Server
+	config   *config.Config
+	sinks    *sink.Fanout
+	buffer   *buffer.Buffer
+	keys     *auth.KeyStore
+	limiter  *ratelimit.Limiter
+	pipeline *pipeline.Pipeline
+	dedup    *dedup.Store
+	metrics  *metrics.Metrics
 }
 
-func NewServer(cfg *config.Config, vl *storage.VictoriaLogsClient) *Server {
+// NewServer creates the ingestion API server writing to sinks. keys may be
+// nil, in which case ingestion is unauthenticated, pl may be nil to store
+// entries as received, and m may be nil to serve no /metrics.
+func NewServer(cfg *config.Config, sinks *sink.Fanout, keys *auth.KeyStore, pl *pipeline.Pipeline, m *metrics.Metrics) *Server {
 	if cfg.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
 	router := gin.Default()
 
+	// Only forwarded client IPs from trusted proxies are believed; none by
+	// default. Entries are validated by config.Load.
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Printf("Invalid trusted proxies: %v", err)
+	}
+
 	// Add middleware
 	router.Use(gin.Logger())
 	router.Use(gin.Recovery())
 	router.Use(corsMiddleware())
 
 	server := &Server{
-		router:       router,
-		config:       cfg,
-		victoriaLogs: vl,
+		router:   router,
+		config:   cfg,
+		sinks:    sinks,
+		keys:     keys,
+		pipeline: pl,
+		metrics:  m,
+		buffer: buffer.New(buffer.Options{
+			Capacity:      cfg.BufferSize,
+			BatchSize:     cfg.BatchSize,
+			FlushInterval: cfg.FlushInterval,
+			Workers:       cfg.FlushWorkers,
+		}, sinks.Write),
 		server: &http.Server{
 			Addr:    ":" + cfg.ServerPort,
 			Handler: router,
 		},
 	}
 
+	if cfg.DedupWindow > 0 {
+		server.dedup = dedup.New(dedup.Options{Window: cfg.DedupWindow, MaxKeys: cfg.DedupMaxKeys})
+	}
+
+	limits := rateLimitOptions(cfg)
+	if limits.Enabled() {
+		server.limiter = ratelimit.New(limits)
+	}
+
+	if m != nil {
+		router.Use(server.observeRequests())
+		server.watchState()
+	}
+
 	server.setupRoutes()
 
 	return server
@@ -48,9 +94,179 @@ func (s *Server) setupRoutes() {
 	// Health check
 	s.router.GET("/health", s.healthCheck)
 
+	// Prometheus metrics
+	if s.metrics != nil {
+		s.router.GET("/metrics", gin.WrapH(s.metrics.Handler()))
+	}
+
 	// Ingestion endpoints
-	s.router.POST("/ingest/logs", s.ingestLogs)
-	s.router.POST("/ingest/batch", s.ingestBatchLogs)
+	ingest := s.ingestGroup("/ingest", s.config.MaxBodySize, true)
+	{
+		ingest.POST("/logs", s.ingestLogs)
+		ingest.POST("/batch", s.ingestBatchLogs)
+	}
+
+	// Streams are bounded per line rather than per body, and are not
+	// buffered for idempotency
+	stream := s.ingestGroup("/ingest", 0, false)
+	{
+		stream.POST("/stream", s.ingestStream)
+	}
+
+	// OpenTelemetry OTLP/HTTP receiver
+	otlp := s.ingestGroup("/v1", s.config.MaxBodySize, true)
+	{
+		otlp.POST("/logs", s.ingestOTLPLogs)
+	}
+
+	// Loki push API compatibility
+	loki := s.ingestGroup("/loki/api/v1", s.config.MaxBodySize, true)
+	{
+		loki.POST("/push", s.ingestLokiPush)
+	}
+
+	// Elasticsearch _bulk API compatibility
+	s.router.GET("/", s.elasticsearchInfo)
+	s.router.HEAD("/", s.elasticsearchInfo)
+	elasticsearch := s.ingestGroup("", s.config.MaxBodySize, true)
+	{
+		elasticsearch.POST("/_bulk", s.ingestElasticsearchBulk)
+		elasticsearch.POST("/:index/_bulk", s.ingestElasticsearchBulk)
+	}
+}
+
+// ingestGroup creates a route group that requires an API key when
+// authentication is enabled. Bodies are capped at maxBodySize bytes as
+// received, unless it is zero. Idempotency keys are honoured if idempotent
+// is set, which reads whole bodies.
+func (s *Server) ingestGroup(path string, maxBodySize int64, idempotent bool) *gin.RouterGroup {
+	group := s.router.Group(path)
+	if s.keys != nil {
+		group.Use(s.apiKeyAuth())
+	}
+	if maxBodySize > 0 {
+		group.Use(limitBody(maxBodySize))
+	}
+	if idempotent && s.dedup != nil {
+		group.Use(s.idempotency())
+	}
+	group.Use(decompressBody(s.config.MaxDecompressedSize))
+	if s.limiter != nil {
+		group.Use(s.rateLimit())
+	}
+	return group
+}
+
+func rateLimitOptions(cfg *config.Config) ratelimit.Options {
+	limit := func(rate int) ratelimit.Limit {
+		return ratelimit.Limit{
+			Rate:  float64(rate),
+			Burst: float64(rate) * cfg.RateLimitBurst.Seconds(),
+		}
+	}
+
+	return ratelimit.Options{
+		KeyEvents:     limit(cfg.RateLimitKeyEvents),
+		KeyBytes:      limit(cfg.RateLimitKeyBytes),
+		ProjectEvents: limit(cfg.RateLimitProjectEvents),
+		ProjectBytes:  limit(cfg.RateLimitProjectBytes),
+	}
+}
+
+// Ingest runs entries received by any protocol through the entry limits
+// and the parsing pipeline and hands them to the background writer. Oversized
+// values are truncated as for HTTP requests, but entries that fail
+// validation are dropped, as there is no response to report them in.
+func (s *Server) Ingest(logs []storage.LogEntry) error {
+	valid := logs[:0]
+	for i := range logs {
+		if err := s.validateEntry(&logs[i]); err != nil {
+			log.Printf("Dropping invalid log entry from %s: %v", logs[i].Source, err)
+			continue
+		}
+		valid = append(valid, logs[i])
+	}
+	return s.enqueue(context.Background(), s.process(nil, valid), false)
+}
+
+// process runs logs through the pipeline, if one is configured, and
+// enriches the entries to keep. c is nil outside of HTTP requests.
+func (s *Server) process(c *gin.Context, logs []storage.LogEntry) []storage.LogEntry {
+	s.normalizeLevels(logs)
+	if s.pipeline != nil {
+		logs = s.pipeline.Process(logs)
+		// Fields and levels parsed from messages are limited and
+		// normalised too
+		for i := range logs {
+			s.limitFields(&logs[i])
+		}
+		s.normalizeLevels(logs)
+	}
+	if s.config.EnrichMetadata {
+		s.enrich(c, logs)
+	}
+	return logs
+}
+
+// ingestValidated admits logs against the caller's rate limits before
+// processing them with the request's metadata and enqueueing them.
+func (s *Server) ingestValidated(c *gin.Context, logs []storage.LogEntry) error {
+	if err := s.admit(c, len(logs)); err != nil {
+		return err
+	}
+	return s.enqueue(c.Request.Context(), s.process(c, logs), false)
+}
+
+// enqueue drops events already ingested within the dedup window and hands
+// the rest to the buffer in chunks it can hold, so that the size of a
+// request does not depend on BUFFER_SIZE. A full buffer fails the request
+// up front unless wait is set, but once a chunk is in, later chunks wait
+// for room as in enqueueWait rather than fail the request halfway. Entries
+// that could not be enqueued have their event IDs released.
+func (s *Server) enqueue(ctx context.Context, logs []storage.LogEntry, wait bool) error {
+	logs = s.claimEvents(logs)
+	size := s.buffer.Capacity()
+	for start := 0; start < len(logs); start += size {
+		chunk := logs[start:min(start+size, len(logs))]
+		var err error
+		if wait || start > 0 {
+			err = s.enqueueWait(ctx, chunk)
+		} else {
+			err = s.buffer.Enqueue(chunk)
+		}
+		if err != nil {
+			s.releaseEvents(logs[start:])
+			s.rejected(logs[start:], err)
+			return err
+		}
+		s.accepted(chunk)
+	}
+	return nil
+}
+
+// admit charges events and the body bytes read so far to the caller's rate
+// limits, returning a *ratelimit.Error when they are exceeded.
+func (s *Server) admit(c *gin.Context, events int) error {
+	if s.limiter == nil {
+		return nil
+	}
+
+	bytes := 0
+	if value, ok := c.Get(bodyCounterKey); ok {
+		bytes = value.(*countingBody).take()
+	}
+
+	decision := s.limiter.Allow(ratelimit.Request{
+		KeyID:     keyID(c),
+		ProjectID: projectID(c),
+		Events:    events,
+		Bytes:     bytes,
+	})
+	if !decision.Allowed {
+		return &ratelimit.Error{Decision: decision}
+	}
+	setRateLimitHeaders(c, decision)
+	return nil
 }
 
 func (s *Server) Start(addr string) error {
@@ -60,14 +276,24 @@ func (s *Server) Start(addr string) error {
 func (s *Server) Shutdown() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
-	return s.server.Shutdown(ctx)
+
+	if err := s.server.Shutdown(ctx); err != nil {
+		return err
+	}
+
+	// Drain buffered logs once no new requests can arrive, then the queue
+	// of each sink
+	if err := s.buffer.Close(ctx); err != nil {
+		return err
+	}
+	return s.sinks.Close(ctx)
 }
 
 func corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization, X-API-Key")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bizjs/Lograil/ingestion/internal/config"
+	"github.com/bizjs/Lograil/ingestion/internal/metrics"
+	"github.com/bizjs/Lograil/ingestion/internal/pipeline"
+	"github.com/bizjs/Lograil/ingestion/internal/sink"
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// newTestSinks fans out to out alone, dropping batches it fails to write.
+func newTestSinks(t *testing.T, out storage.Sink) *sink.Fanout {
+	t.Helper()
+
+	sinks, err := sink.New([]sink.Config{{Name: "test", Sink: out, OnFailure: sink.PolicyDrop}})
+	if err != nil {
+		t.Fatalf("sink.New failed: %v", err)
+	}
+	return sinks
+}
+
+// newTestServer creates a server that writes to a memorySink, without a
+// pipeline or metrics. Unset batching settings default to a buffer of 10
+// entries that is flushed when full or on shutdown.
+func newTestServer(t *testing.T, cfg config.Config) (*Server, *memorySink) {
+	t.Helper()
+	return newTestServerWith(t, cfg, nil, nil)
+}
+
+// newTestServerWith is newTestServer with a pipeline and metrics.
+func newTestServerWith(t *testing.T, cfg config.Config, pl *pipeline.Pipeline, m *metrics.Metrics) (*Server, *memorySink) {
+	t.Helper()
+
+	if cfg.BatchSize == 0 {
+		cfg.BatchSize = 10
+	}
+	if cfg.BufferSize == 0 {
+		cfg.BufferSize = 10
+	}
+	if cfg.FlushInterval == 0 {
+		cfg.FlushInterval = time.Hour
+	}
+	if cfg.FlushWorkers == 0 {
+		cfg.FlushWorkers = 1
+	}
+
+	out := &memorySink{}
+	return NewServer(&cfg, newTestSinks(t, out), nil, pl, m), out
+}
+
+// memorySink records the entries written to it.
+type memorySink struct {
+	mu      sync.Mutex
+	written []storage.LogEntry
+	health  error
+}
+
+func (s *memorySink) Write(logs []storage.LogEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.written = append(s.written, logs...)
+	return nil
+}
+
+func (s *memorySink) Health() error { return s.health }
+func (s *memorySink) Close() error  { return nil }
+
+func (s *memorySink) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.written)
+}
+
+func TestSinkFanout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	primary := &memorySink{}
+	secondary := &memorySink{health: errors.New("disk full")}
+	sinks, err := sink.New([]sink.Config{
+		{Name: "primary", Sink: primary, OnFailure: sink.PolicyDrop},
+		{Name: "secondary", Sink: secondary, OnFailure: sink.PolicyDrop},
+	})
+	if err != nil {
+		t.Fatalf("sink.New failed: %v", err)
+	}
+	server := NewServer(&config.Config{
+		BatchSize:     10,
+		BufferSize:    10,
+		FlushInterval: time.Hour,
+		FlushWorkers:  1,
+	}, sinks, nil, nil, nil)
+
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 while a sink is unhealthy, got %d", recorder.Code)
+	}
+	var health struct {
+		Sinks []sink.Status `json:"sinks"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &health); err != nil {
+		t.Fatalf("Invalid response: %v", err)
+	}
+	if len(health.Sinks) != 2 || !health.Sinks[0].Healthy || health.Sinks[1].Healthy || health.Sinks[1].Error != "disk full" {
+		t.Errorf("Unexpected sink health: %+v", health.Sinks)
+	}
+
+	if err := server.Ingest([]storage.LogEntry{{Level: "info", Message: "a", Source: "s"}, {Level: "info", Message: "b", Source: "s"}}); err != nil {
+		t.Fatalf("Ingest failed: %v", err)
+	}
+	if err := server.Shutdown(); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if primary.count() != 2 || secondary.count() != 2 {
+		t.Errorf("Expected every sink to get both entries, got %d and %d", primary.count(), secondary.count())
+	}
+}
+
+func TestIngestLargerThanBuffer(t *testing.T) {
+	server, out := newTestServer(t, config.Config{})
+
+	var body strings.Builder
+	for i := 0; i < 25; i++ {
+		body.WriteString(`{"index":{"_index":"app"}}` + "\n")
+		fmt.Fprintf(&body, `{"message":"m%d","log.level":"info"}`+"\n", i)
+	}
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/_bulk", strings.NewReader(body.String()))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	server.router.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected a request over the buffer capacity to be accepted, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	if err := server.Shutdown(); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if out.count() != 25 {
+		t.Errorf("Expected 25 entries written, got %d: %s", out.count(), recorder.Body.String())
+	}
+}
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/bizjs/Lograil/ingestion/internal/buffer"
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxStreamLineSize bounds a single NDJSON line.
+	maxStreamLineSize = 1024 * 1024
+	// maxStreamErrors bounds the line errors listed in a response.
+	maxStreamErrors = 100
+	// streamEnqueueTimeout is how long a stream waits for buffer space.
+	streamEnqueueTimeout = 30 * time.Second
+)
+
+type lineError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// Streaming NDJSON ingestion handler. Lines are decoded as they arrive and
+// handed to the buffer in BatchSize chunks; invalid lines are reported
+// without aborting the stream.
+func (s *Server) ingestStream(c *gin.Context) {
+	batchSize := min(s.config.BatchSize, s.buffer.Capacity())
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+
+	project := projectID(c)
+	reader := bufio.NewReaderSize(c.Request.Body, 64*1024)
+	batch := make([]storage.LogEntry, 0, batchSize)
+
+	accepted, rejected := 0, 0
+	lineErrors := []lineError{}
+	reject := func(line int, err error) {
+		rejected++
+		if len(lineErrors) < maxStreamErrors {
+			lineErrors = append(lineErrors, lineError{Line: line, Error: err.Error()})
+		}
+	}
+
+	for lineNumber := 1; ; lineNumber++ {
+		line, readErr := readStreamLine(reader)
+		if readErr != nil && readErr != io.EOF && !errors.Is(readErr, errLineTooLong) {
+			c.JSON(bodyErrorStatus(readErr), gin.H{
+				"error":    readErr.Error(),
+				"accepted": accepted,
+				"rejected": rejected,
+				"errors":   lineErrors,
+			})
+			return
+		}
+
+		if errors.Is(readErr, errLineTooLong) {
+			reject(lineNumber, readErr)
+		} else if len(bytes.TrimSpace(line)) > 0 {
+			entry, err := decodeEntry(line, project)
+			if err == nil {
+				err = s.validateEntry(&entry)
+			}
+			if err != nil {
+				reject(lineNumber, err)
+			} else {
+				batch = append(batch, entry)
+			}
+		}
+
+		if len(batch) == batchSize || (readErr == io.EOF && len(batch) > 0) {
+			if err := s.ingestWait(c, batch); err != nil {
+				s.respondStreamError(c, err, accepted, rejected, lineErrors)
+				return
+			}
+			accepted += len(batch)
+			batch = batch[:0]
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	if accepted == 0 && rejected == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No logs provided"})
+		return
+	}
+
+	status := http.StatusAccepted
+	if accepted == 0 {
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, gin.H{
+		"message":  "Logs accepted",
+		"accepted": accepted,
+		"rejected": rejected,
+		"errors":   lineErrors,
+	})
+}
+
+var errLineTooLong = fmt.Errorf("line exceeds %d bytes", maxStreamLineSize)
+
+// readStreamLine returns the next line without its newline. An oversized
+// line is skipped and reported as errLineTooLong; the last line is
+// returned with io.EOF.
+func readStreamLine(reader *bufio.Reader) ([]byte, error) {
+	var line []byte
+	tooLong := false
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if !tooLong {
+			if len(line)+len(chunk) > maxStreamLineSize+1 {
+				tooLong, line = true, nil
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+
+		switch {
+		case err == bufio.ErrBufferFull:
+			continue
+		case err == io.EOF && tooLong:
+			return nil, errLineTooLong
+		case err != nil:
+			return bytes.TrimRight(line, "\r\n"), err
+		case tooLong:
+			return nil, errLineTooLong
+		default:
+			return bytes.TrimRight(line, "\r\n"), nil
+		}
+	}
+}
+
+// ingestWait admits logs against the rate limits once, then retries a
+// full buffer so that a slow sink throttles the stream instead of failing
+// it, up to streamEnqueueTimeout.
+func (s *Server) ingestWait(c *gin.Context, logs []storage.LogEntry) error {
+	if err := s.admit(c, len(logs)); err != nil {
+		return err
+	}
+
+	// Process once, so retries do not sample or redact twice
+	return s.enqueue(c.Request.Context(), s.process(c, logs), true)
+}
+
+// enqueueWait retries while the buffer is full, until ctx ends or
+// streamEnqueueTimeout elapses.
+func (s *Server) enqueueWait(ctx context.Context, logs []storage.LogEntry) error {
+	deadline := time.NewTimer(streamEnqueueTimeout)
+	defer deadline.Stop()
+
+	for {
+		err := s.buffer.Enqueue(logs)
+		if !errors.Is(err, buffer.ErrFull) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline.C:
+			return err
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// respondStreamError reports a failed enqueue together with how much of
+// the stream was accepted, so that clients can resume after it.
+func (s *Server) respondStreamError(c *gin.Context, err error, accepted, rejected int, lineErrors []lineError) {
+	c.JSON(enqueueErrorStatus(c, err), gin.H{
+		"error":    err.Error(),
+		"accepted": accepted,
+		"rejected": rejected,
+		"errors":   lineErrors,
+	})
+}
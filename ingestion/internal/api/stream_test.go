@@ -0,0 +1,87 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bizjs/Lograil/ingestion/internal/config"
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+func TestIngestStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var mu sync.Mutex
+	var written []string
+	victoriaLogs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scanner := bufio.NewScanner(r.Body)
+		mu.Lock()
+		for scanner.Scan() {
+			written = append(written, scanner.Text())
+		}
+		mu.Unlock()
+	}))
+	defer victoriaLogs.Close()
+
+	vl, err := storage.NewVictoriaLogsClient(victoriaLogs.URL, storage.VictoriaLogsOptions{})
+	if err != nil {
+		t.Fatalf("NewVictoriaLogsClient failed: %v", err)
+	}
+
+	server := NewServer(&config.Config{
+		BatchSize:     2,
+		BufferSize:    10,
+		FlushInterval: 10 * time.Millisecond,
+		FlushWorkers:  1,
+	}, newTestSinks(t, vl), nil, nil, nil)
+
+	body := strings.Join([]string{
+		`{"level":"info","message":"one","source":"job"}`,
+		`{"level":"info",`,
+		`{"level":"info","source":"job"}`,
+		``,
+		`{"level":"warn","message":"two","source":"job"}`,
+		`{"level":"error","message":"three","source":"job"}`,
+	}, "\n")
+
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/ingest/stream", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	server.router.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202, got %d: %s", recorder.Code, recorder.Body)
+	}
+
+	var resp struct {
+		Accepted int         `json:"accepted"`
+		Rejected int         `json:"rejected"`
+		Errors   []lineError `json:"errors"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Invalid response: %v", err)
+	}
+	if resp.Accepted != 3 || resp.Rejected != 2 {
+		t.Errorf("Expected 3 accepted and 2 rejected, got %+v", resp)
+	}
+	if len(resp.Errors) != 2 || resp.Errors[0].Line != 2 || resp.Errors[1].Line != 3 {
+		t.Errorf("Unexpected line errors: %+v", resp.Errors)
+	}
+
+	if err := server.Shutdown(); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(written) != 3 {
+		t.Errorf("Expected 3 written entries, got %d: %v", len(written), written)
+	}
+}
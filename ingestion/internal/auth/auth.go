@@ -0,0 +1,222 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bizjs/Lograil/pkg/apikey"
+	"github.com/bizjs/Lograil/pkg/data"
+	dataapikey "github.com/bizjs/Lograil/pkg/data/apikey"
+)
+
+var (
+	// ErrInvalidKey is returned for unknown keys.
+	ErrInvalidKey = errors.New("invalid API key")
+	// ErrInactiveKey is returned for keys that were deactivated.
+	ErrInactiveKey = errors.New("API key is inactive")
+	// ErrExpiredKey is returned for keys past their expiry time.
+	ErrExpiredKey = errors.New("API key has expired")
+	// ErrForbidden is returned when a key lacks the required permission.
+	ErrForbidden = errors.New("API key lacks write permission")
+	// ErrInactiveProject is returned when the key's project is not active.
+	ErrInactiveProject = errors.New("project is not active")
+)
+
+// maxCachedKeys bounds the cache so that floods of unknown keys cannot
+// grow it without limit.
+const maxCachedKeys = 10000
+
+// Principal identifies the key and project a request was authenticated as.
+type Principal struct {
+	KeyID         int
+	KeyName       string
+	ProjectID     int
+	ProjectName   string
+	ProjectStatus string
+	Permissions   string
+	IsActive      bool
+	ExpiresAt     time.Time
+}
+
+// Options configures a KeyStore.
+type Options struct {
+	// CacheTTL is how long a verified key is served from memory before it
+	// is looked up again.
+	CacheTTL time.Duration
+	// TouchInterval is how often last_used_at updates are written back.
+	TouchInterval time.Duration
+}
+
+// KeyStore verifies API keys against the APIKey table. Lookups are cached
+// and last_used_at is updated in the background, so an authenticated
+// request normally costs no database round trip.
+type KeyStore struct {
+	client *data.Client
+	opts   Options
+
+	mu      sync.Mutex
+	cache   map[string]cachedKey
+	touched map[int]time.Time
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type cachedKey struct {
+	principal *Principal
+	err       error
+	loadedAt  time.Time
+}
+
+// NewKeyStore creates a KeyStore and starts the last_used_at writer.
+func NewKeyStore(client *data.Client, opts Options) *KeyStore {
+	if opts.CacheTTL <= 0 {
+		opts.CacheTTL = time.Minute
+	}
+	if opts.TouchInterval <= 0 {
+		opts.TouchInterval = 30 * time.Second
+	}
+
+	ks := &KeyStore{
+		client:  client,
+		opts:    opts,
+		cache:   make(map[string]cachedKey),
+		touched: make(map[int]time.Time),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	go ks.touchLoop()
+
+	return ks
+}
+
+// Authenticate verifies a raw key and checks that it may write logs.
+func (ks *KeyStore) Authenticate(ctx context.Context, rawKey string) (*Principal, error) {
+	if rawKey == "" {
+		return nil, ErrInvalidKey
+	}
+
+	principal, err := ks.lookup(ctx, apikey.Hash(rawKey))
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case !principal.IsActive:
+		return nil, ErrInactiveKey
+	case !principal.ExpiresAt.IsZero() && time.Now().After(principal.ExpiresAt):
+		return nil, ErrExpiredKey
+	case principal.ProjectStatus != "active":
+		return nil, ErrInactiveProject
+	case !apikey.HasPermission(principal.Permissions, apikey.PermissionWrite):
+		return nil, ErrForbidden
+	}
+
+	ks.mu.Lock()
+	ks.touched[principal.KeyID] = time.Now()
+	ks.mu.Unlock()
+
+	return principal, nil
+}
+
+// Close stops the background writer after flushing pending updates.
+func (ks *KeyStore) Close() error {
+	close(ks.stop)
+	<-ks.done
+	return nil
+}
+
+func (ks *KeyStore) lookup(ctx context.Context, hash string) (*Principal, error) {
+	ks.mu.Lock()
+	cached, ok := ks.cache[hash]
+	ks.mu.Unlock()
+	if ok && time.Since(cached.loadedAt) < ks.opts.CacheTTL {
+		return cached.principal, cached.err
+	}
+
+	key, err := ks.client.APIKey.Query().
+		Where(dataapikey.HashedKey(hash)).
+		WithProject().
+		Only(ctx)
+	if err != nil && !data.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to look up API key: %w", err)
+	}
+
+	entry := cachedKey{loadedAt: time.Now()}
+	if data.IsNotFound(err) {
+		entry.err = ErrInvalidKey
+	} else {
+		entry.principal = &Principal{
+			KeyID:       key.ID,
+			KeyName:     key.Name,
+			Permissions: key.Permissions,
+			ExpiresAt:   key.ExpiresAt,
+			IsActive:    key.IsActive,
+		}
+		if project := key.Edges.Project; project != nil {
+			entry.principal.ProjectID = project.ID
+			entry.principal.ProjectName = project.Name
+			entry.principal.ProjectStatus = project.Status
+		}
+	}
+
+	ks.mu.Lock()
+	if len(ks.cache) >= maxCachedKeys {
+		ks.evictExpired()
+	}
+	ks.cache[hash] = entry
+	ks.mu.Unlock()
+
+	return entry.principal, entry.err
+}
+
+// evictExpired drops stale cache entries, or the whole cache if none are
+// stale. Callers hold ks.mu.
+func (ks *KeyStore) evictExpired() {
+	for hash, entry := range ks.cache {
+		if time.Since(entry.loadedAt) >= ks.opts.CacheTTL {
+			delete(ks.cache, hash)
+		}
+	}
+	if len(ks.cache) >= maxCachedKeys {
+		ks.cache = make(map[string]cachedKey)
+	}
+}
+
+func (ks *KeyStore) touchLoop() {
+	defer close(ks.done)
+
+	ticker := time.NewTicker(ks.opts.TouchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			ks.flushTouched()
+		case <-ks.stop:
+			ks.flushTouched()
+			return
+		}
+	}
+}
+
+func (ks *KeyStore) flushTouched() {
+	ks.mu.Lock()
+	touched := ks.touched
+	ks.touched = make(map[int]time.Time)
+	ks.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for id, usedAt := range touched {
+		if err := ks.client.APIKey.UpdateOneID(id).SetLastUsedAt(usedAt).Exec(ctx); err != nil {
+			log.Printf("Failed to update last_used_at for API key %d: %v", id, err)
+		}
+	}
+}
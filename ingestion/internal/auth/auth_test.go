@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bizjs/Lograil/ingestion/internal/database"
+	"github.com/bizjs/Lograil/pkg/apikey"
+)
+
+func TestKeyStoreAuthenticate(t *testing.T) {
+	client, err := database.NewEntClient("file:auth_test?mode=memory&cache=shared&_fk=1")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer client.Close()
+
+	ctx := context.Background()
+	if err := client.Schema.Create(ctx); err != nil {
+		t.Fatalf("Failed to create schema: %v", err)
+	}
+
+	owner := client.User.Create().
+		SetUsername("owner").
+		SetEmail("owner@example.com").
+		SetPasswordHash("hashedpassword").
+		SaveX(ctx)
+	project := client.Project.Create().SetName("shop").SetOwner(owner).SaveX(ctx)
+
+	newKey := func(raw, permissions string) {
+		client.APIKey.Create().
+			SetName(raw).
+			SetHashedKey(apikey.Hash(raw)).
+			SetPermissions(permissions).
+			SetProject(project).
+			SetCreatedBy(owner).
+			SaveX(ctx)
+	}
+	newKey("writer", "write")
+	newKey("reader", "read")
+	client.APIKey.Create().
+		SetName("expired").
+		SetHashedKey(apikey.Hash("expired")).
+		SetPermissions("write").
+		SetExpiresAt(time.Now().Add(-time.Hour)).
+		SetProject(project).
+		SetCreatedBy(owner).
+		SaveX(ctx)
+
+	keys := NewKeyStore(client, Options{TouchInterval: time.Hour})
+
+	principal, err := keys.Authenticate(ctx, "writer")
+	if err != nil {
+		t.Fatalf("Expected writer key to authenticate: %v", err)
+	}
+	if principal.ProjectID != project.ID || principal.ProjectName != "shop" {
+		t.Errorf("Key bound to wrong project: %+v", principal)
+	}
+
+	cases := map[string]error{
+		"reader":  ErrForbidden,
+		"expired": ErrExpiredKey,
+		"unknown": ErrInvalidKey,
+	}
+	for raw, want := range cases {
+		if _, err := keys.Authenticate(ctx, raw); !errors.Is(err, want) {
+			t.Errorf("Key %q: expected %v, got %v", raw, want, err)
+		}
+	}
+
+	// Close flushes last_used_at for keys used since the last flush
+	if err := keys.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	stored := client.APIKey.GetX(ctx, principal.KeyID)
+	if stored.LastUsedAt.IsZero() {
+		t.Error("Expected last_used_at to be recorded")
+	}
+}
@@ -0,0 +1,208 @@
+package buffer
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+)
+
+var (
+	// ErrFull is returned when the buffer cannot accept more entries.
+	ErrFull = errors.New("ingestion buffer is full")
+	// ErrTooLarge is returned when a single request exceeds the buffer capacity.
+	ErrTooLarge = errors.New("batch exceeds ingestion buffer capacity")
+	// ErrClosed is returned when entries are enqueued after Close.
+	ErrClosed = errors.New("ingestion buffer is closed")
+)
+
+// WriteFunc persists a batch of log entries.
+type WriteFunc func([]storage.LogEntry) error
+
+// Options configures a Buffer.
+type Options struct {
+	// Capacity is the maximum number of entries held in memory.
+	Capacity int
+	// BatchSize is the maximum number of entries handed to WriteFunc at once.
+	BatchSize int
+	// FlushInterval is the maximum time an entry lingers before a partial
+	// batch is flushed.
+	FlushInterval time.Duration
+	// Workers is the number of concurrent flushers.
+	Workers int
+}
+
+// Buffer is a bounded in-memory queue that flushes log entries to storage
+// in the background, either when a full batch is available or when
+// FlushInterval elapses.
+type Buffer struct {
+	opts  Options
+	write WriteFunc
+
+	mu      sync.Mutex
+	pending []storage.LogEntry
+	closed  bool
+
+	notify  chan struct{}
+	closing chan struct{}
+	wg      sync.WaitGroup
+}
+
+// New creates a Buffer and starts its flush workers.
+func New(opts Options, write WriteFunc) *Buffer {
+	if opts.Capacity <= 0 {
+		opts.Capacity = 1000
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.BatchSize > opts.Capacity {
+		opts.BatchSize = opts.Capacity
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+
+	b := &Buffer{
+		opts:    opts,
+		write:   write,
+		pending: make([]storage.LogEntry, 0, opts.Capacity),
+		notify:  make(chan struct{}, opts.Workers),
+		closing: make(chan struct{}),
+	}
+
+	for i := 0; i < opts.Workers; i++ {
+		b.wg.Add(1)
+		go b.worker()
+	}
+
+	return b
+}
+
+// Enqueue adds entries to the buffer. Either all entries are accepted or
+// none are.
+func (b *Buffer) Enqueue(entries []storage.LogEntry) error {
+	if len(entries) > b.opts.Capacity {
+		return ErrTooLarge
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrClosed
+	}
+	if len(b.pending)+len(entries) > b.opts.Capacity {
+		b.mu.Unlock()
+		return ErrFull
+	}
+	b.pending = append(b.pending, entries...)
+	ready := len(b.pending) >= b.opts.BatchSize
+	b.mu.Unlock()
+
+	if ready {
+		select {
+		case b.notify <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// Len returns the number of entries waiting to be flushed.
+func (b *Buffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.pending)
+}
+
+// Capacity returns the maximum number of buffered entries.
+func (b *Buffer) Capacity() int {
+	return b.opts.Capacity
+}
+
+// Close stops accepting entries and flushes everything still buffered.
+// It returns ctx.Err() if draining does not finish in time.
+func (b *Buffer) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	b.mu.Unlock()
+
+	close(b.closing)
+
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *Buffer) worker() {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(b.opts.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.notify:
+			b.flush(false)
+		case <-ticker.C:
+			b.flush(true)
+		case <-b.closing:
+			b.flush(true)
+			return
+		}
+	}
+}
+
+// flush writes full batches until fewer than BatchSize entries remain. When
+// partial is true, the remaining entries are written as well.
+func (b *Buffer) flush(partial bool) {
+	for {
+		batch := b.take(partial)
+		if len(batch) == 0 {
+			return
+		}
+
+		if err := b.write(batch); err != nil {
+			log.Printf("Failed to flush %d buffered logs: %v", len(batch), err)
+		}
+	}
+}
+
+func (b *Buffer) take(partial bool) []storage.LogEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := len(b.pending)
+	if n == 0 || (n < b.opts.BatchSize && !partial) {
+		return nil
+	}
+	if n > b.opts.BatchSize {
+		n = b.opts.BatchSize
+	}
+
+	batch := make([]storage.LogEntry, n)
+	copy(batch, b.pending[:n])
+	b.pending = append(b.pending[:0], b.pending[n:]...)
+
+	return batch
+}
@@ -0,0 +1,73 @@
+package buffer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+)
+
+func TestBufferFlushesAndDrains(t *testing.T) {
+	var mu sync.Mutex
+	var batches [][]storage.LogEntry
+
+	b := New(Options{Capacity: 10, BatchSize: 3, FlushInterval: time.Hour, Workers: 1}, func(logs []storage.LogEntry) error {
+		mu.Lock()
+		defer mu.Unlock()
+		batches = append(batches, logs)
+		return nil
+	})
+
+	if err := b.Enqueue(make([]storage.LogEntry, 4)); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+	if err := b.Enqueue(make([]storage.LogEntry, 7)); !errors.Is(err, ErrFull) {
+		t.Errorf("Expected ErrFull, got %v", err)
+	}
+	if err := b.Enqueue(make([]storage.LogEntry, 11)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected ErrTooLarge, got %v", err)
+	}
+
+	if err := b.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := b.Enqueue(make([]storage.LogEntry, 1)); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+
+	total := 0
+	for _, batch := range batches {
+		if len(batch) > 3 {
+			t.Errorf("Batch of %d exceeds BatchSize", len(batch))
+		}
+		total += len(batch)
+	}
+	if total != 4 || b.Len() != 0 {
+		t.Errorf("Expected 4 flushed entries and empty buffer, got %d flushed, %d pending", total, b.Len())
+	}
+}
+
+func TestBufferFlushesPartialBatchAfterInterval(t *testing.T) {
+	flushed := make(chan int, 1)
+	b := New(Options{Capacity: 10, BatchSize: 5, FlushInterval: 10 * time.Millisecond}, func(logs []storage.LogEntry) error {
+		flushed <- len(logs)
+		return nil
+	})
+	defer b.Close(context.Background())
+
+	if err := b.Enqueue(make([]storage.LogEntry, 2)); err != nil {
+		t.Fatalf("Enqueue failed: %v", err)
+	}
+
+	select {
+	case n := <-flushed:
+		if n != 2 {
+			t.Errorf("Expected partial batch of 2, got %d", n)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Partial batch was not flushed")
+	}
+}
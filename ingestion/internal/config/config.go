@@ -1,10 +1,38 @@
 package config
 
 import (
+	"fmt"
+	"net/netip"
 	"os"
+	"path"
 	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bizjs/Lograil/ingestion/internal/parser"
+	"github.com/bizjs/Lograil/ingestion/internal/sink"
+	"github.com/bizjs/Lograil/pkg/tenant"
+)
+
+// Sink names accepted in SINKS.
+const (
+	SinkVictoriaLogs          = "victorialogs"
+	SinkSecondaryVictoriaLogs = "secondary_victorialogs"
+	SinkFile                  = "file"
+	SinkStdout                = "stdout"
 )
 
+// SinkConfig is the batching and failure policy of one storage sink,
+// read from SINK_<NAME>_* variables.
+type SinkConfig struct {
+	Name          string
+	BatchSize     int
+	BufferSize    int
+	FlushInterval time.Duration
+	Workers       int
+	OnFailure     sink.Policy
+}
+
 type Config struct {
 	ServerPort      string
 	VictoriaLogsURL string
@@ -12,21 +40,287 @@ type Config struct {
 	BatchSize       int
 	BufferSize      int
 	Environment     string
+	DatabaseURL     string
+
+	// API key authentication for ingest endpoints
+	AuthEnabled         bool
+	APIKeyCacheTTL      time.Duration
+	APIKeyTouchInterval time.Duration
+
+	// Cap on Content-Encoding decoded request bodies
+	MaxDecompressedSize int64
+
+	// Payload limits; zero disables a limit
+	MaxBodySize       int64
+	MaxMessageSize    int
+	MaxFieldValueSize int
+	MaxFields         int
+	MaxFieldDepth     int
+	MaxFieldKeyLength int
+
+	// Token-bucket rate limits per second; zero disables a limit
+	RateLimitKeyEvents     int
+	RateLimitKeyBytes      int
+	RateLimitProjectEvents int
+	RateLimitProjectBytes  int
+	RateLimitBurst         time.Duration
+
+	// JSON file defining parsing pipelines per source
+	PipelineConfig string
+
+	// Idempotency keys and event IDs remembered for deduplication
+	DedupWindow  time.Duration
+	DedupMaxKeys int
+
+	// Canonical levels; strict mode rejects unknown ones
+	NormalizeLevels bool
+	StrictLevels    bool
+	// Numeric level schemes of sources, the first matching one applying
+	LevelSchemes []LevelSchemeRule
+
+	// Reserved metadata fields attached to every entry
+	EnrichMetadata bool
+	NodeID         string
+	TrustedProxies []string
+
+	// Background flushing of buffered logs
+	FlushInterval time.Duration
+	FlushWorkers  int
+
+	// Storage sinks every accepted entry is written to
+	Sinks                    []SinkConfig
+	SecondaryVictoriaLogsURL string
+	FileSinkDir              string
+	FileSinkMaxBytes         int64
+	FileSinkMaxFiles         int
+
+	// Disk spool for batches that cannot be written to a sink
+	SpoolEnabled        bool
+	SpoolDir            string
+	SpoolMaxBytes       int64
+	SpoolSegmentBytes   int64
+	SpoolReplayInterval time.Duration
+
+	// VictoriaLogs ingestion parameters
+	StreamFields []string
+	TimeField    string
+	MsgField     string
+
+	// Syslog listeners
+	SyslogUDPAddr        string
+	SyslogTCPAddr        string
+	SyslogProjectID      int
+	SyslogMaxMessageSize int
+
+	// Fluentd Forward listener
+	ForwardAddr           string
+	ForwardProjectID      int
+	ForwardMaxMessageSize int
+
+	// GELF listeners
+	GELFUDPAddr        string
+	GELFTCPAddr        string
+	GELFProjectID      int
+	GELFMaxMessageSize int
+	GELFChunkTimeout   time.Duration
+
+	// Project isolation in VictoriaLogs
+	TenantMode      tenant.Mode
+	TenantAccountID uint32
+
+	// VictoriaLogs client resilience
+	VictoriaLogsTimeout time.Duration
+	WriteMaxRetries     int
+	WriteRetryBaseDelay time.Duration
+	WriteRetryMaxDelay  time.Duration
+	BreakerThreshold    int
+	BreakerCooldown     time.Duration
 }
 
 func Load() (*Config, error) {
 	cfg := &Config{
-		ServerPort:      getEnv("SERVER_PORT", "9011"),
-		VictoriaLogsURL: getEnv("VICTORIA_LOGS_URL", "http://localhost:9428"),
-		RedisURL:        getEnv("REDIS_URL", "redis://localhost:6379"),
-		BatchSize:       getEnvAsInt("BATCH_SIZE", 100),
-		BufferSize:      getEnvAsInt("BUFFER_SIZE", 1000),
-		Environment:     getEnv("ENVIRONMENT", "development"),
+		ServerPort:             getEnv("SERVER_PORT", "9011"),
+		VictoriaLogsURL:        getEnv("VICTORIA_LOGS_URL", "http://localhost:9428"),
+		RedisURL:               getEnv("REDIS_URL", "redis://localhost:6379"),
+		BatchSize:              getEnvAsInt("BATCH_SIZE", 100),
+		BufferSize:             getEnvAsInt("BUFFER_SIZE", 1000),
+		Environment:            getEnv("ENVIRONMENT", "development"),
+		DatabaseURL:            getEnv("DATABASE_URL", "file:lograil.db?cache=shared&_fk=1"),
+		AuthEnabled:            getEnvAsBool("AUTH_ENABLED", true),
+		APIKeyCacheTTL:         getEnvAsDuration("API_KEY_CACHE_TTL", time.Minute),
+		APIKeyTouchInterval:    getEnvAsDuration("API_KEY_TOUCH_INTERVAL", 30*time.Second),
+		MaxDecompressedSize:    getEnvAsInt64("MAX_DECOMPRESSED_SIZE", 256<<20),
+		MaxBodySize:            getEnvAsInt64("MAX_BODY_SIZE", 32<<20),
+		MaxMessageSize:         getEnvAsInt("MAX_MESSAGE_SIZE", 256*1024),
+		MaxFieldValueSize:      getEnvAsInt("MAX_FIELD_VALUE_SIZE", 32*1024),
+		MaxFields:              getEnvAsInt("MAX_FIELDS", 256),
+		MaxFieldDepth:          getEnvAsInt("MAX_FIELD_DEPTH", 8),
+		MaxFieldKeyLength:      getEnvAsInt("MAX_FIELD_KEY_LENGTH", 128),
+		RateLimitKeyEvents:     getEnvAsInt("RATE_LIMIT_KEY_EVENTS", 0),
+		RateLimitKeyBytes:      getEnvAsInt("RATE_LIMIT_KEY_BYTES", 0),
+		RateLimitProjectEvents: getEnvAsInt("RATE_LIMIT_PROJECT_EVENTS", 0),
+		RateLimitProjectBytes:  getEnvAsInt("RATE_LIMIT_PROJECT_BYTES", 0),
+		RateLimitBurst:         getEnvAsDuration("RATE_LIMIT_BURST", time.Second),
+		PipelineConfig:         getEnv("PIPELINE_CONFIG", ""),
+		DedupWindow:            getEnvAsDuration("DEDUP_WINDOW", 10*time.Minute),
+		DedupMaxKeys:           getEnvAsInt("DEDUP_MAX_KEYS", 100000),
+		NormalizeLevels:        getEnvAsBool("NORMALIZE_LEVELS", true),
+		StrictLevels:           getEnvAsBool("STRICT_LEVELS", false),
+		EnrichMetadata:         getEnvAsBool("ENRICH_METADATA", false),
+		NodeID:                 getEnv("NODE_ID", hostname()),
+		TrustedProxies:         getEnvAsSlice("TRUSTED_PROXIES", nil),
+		FlushInterval:          getEnvAsDuration("FLUSH_INTERVAL", time.Second),
+		FlushWorkers:           getEnvAsInt("FLUSH_WORKERS", 2),
+		FileSinkDir:            getEnv("FILE_SINK_DIR", "data/logs"),
+		FileSinkMaxBytes:       getEnvAsInt64("FILE_SINK_MAX_BYTES", 100<<20),
+		FileSinkMaxFiles:       getEnvAsInt("FILE_SINK_MAX_FILES", 10),
+		SpoolEnabled:           getEnvAsBool("SPOOL_ENABLED", true),
+		SpoolDir:               getEnv("SPOOL_DIR", "data/spool"),
+		SpoolMaxBytes:          getEnvAsInt64("SPOOL_MAX_BYTES", 1<<30),
+		SpoolSegmentBytes:      getEnvAsInt64("SPOOL_SEGMENT_BYTES", 64<<20),
+		SpoolReplayInterval:    getEnvAsDuration("SPOOL_REPLAY_INTERVAL", 5*time.Second),
+		SyslogUDPAddr:          getEnv("SYSLOG_UDP_ADDR", ""),
+		SyslogTCPAddr:          getEnv("SYSLOG_TCP_ADDR", ""),
+		SyslogProjectID:        getEnvAsInt("SYSLOG_PROJECT_ID", 0),
+		SyslogMaxMessageSize:   getEnvAsInt("SYSLOG_MAX_MESSAGE_SIZE", 64*1024),
+		ForwardAddr:            getEnv("FORWARD_ADDR", ""),
+		ForwardProjectID:       getEnvAsInt("FORWARD_PROJECT_ID", 0),
+		ForwardMaxMessageSize:  getEnvAsInt("FORWARD_MAX_MESSAGE_SIZE", 8*1024*1024),
+		GELFUDPAddr:            getEnv("GELF_UDP_ADDR", ""),
+		GELFTCPAddr:            getEnv("GELF_TCP_ADDR", ""),
+		GELFProjectID:          getEnvAsInt("GELF_PROJECT_ID", 0),
+		GELFMaxMessageSize:     getEnvAsInt("GELF_MAX_MESSAGE_SIZE", 1024*1024),
+		GELFChunkTimeout:       getEnvAsDuration("GELF_CHUNK_TIMEOUT", 5*time.Second),
+		StreamFields:           getEnvAsSlice("VICTORIA_LOGS_STREAM_FIELDS", []string{"source"}),
+		TimeField:              getEnv("VICTORIA_LOGS_TIME_FIELD", "_time"),
+		MsgField:               getEnv("VICTORIA_LOGS_MSG_FIELD", "_msg"),
+		VictoriaLogsTimeout:    getEnvAsDuration("VICTORIA_LOGS_TIMEOUT", 30*time.Second),
+		WriteMaxRetries:        getEnvAsInt("VICTORIA_LOGS_MAX_RETRIES", 3),
+		WriteRetryBaseDelay:    getEnvAsDuration("VICTORIA_LOGS_RETRY_BASE_DELAY", 100*time.Millisecond),
+		WriteRetryMaxDelay:     getEnvAsDuration("VICTORIA_LOGS_RETRY_MAX_DELAY", 5*time.Second),
+		BreakerThreshold:       getEnvAsInt("VICTORIA_LOGS_BREAKER_THRESHOLD", 5),
+		BreakerCooldown:        getEnvAsDuration("VICTORIA_LOGS_BREAKER_COOLDOWN", 30*time.Second),
+	}
+
+	tenantMode, err := tenant.ParseMode(getEnv("TENANT_MODE", string(tenant.ModeHeaders)))
+	if err != nil {
+		return nil, err
+	}
+	cfg.TenantMode = tenantMode
+
+	accountID, err := strconv.ParseUint(getEnv("TENANT_ACCOUNT_ID", "0"), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TENANT_ACCOUNT_ID: %w", err)
+	}
+	cfg.TenantAccountID = uint32(accountID)
+
+	levelSchemes, err := loadLevelSchemes()
+	if err != nil {
+		return nil, err
+	}
+	cfg.LevelSchemes = levelSchemes
+
+	sinks, err := loadSinks(cfg)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Sinks = sinks
+
+	for _, proxy := range cfg.TrustedProxies {
+		if _, err := netip.ParsePrefix(proxy); err == nil {
+			continue
+		}
+		if _, err := netip.ParseAddr(proxy); err != nil {
+			return nil, fmt.Errorf("invalid TRUSTED_PROXIES entry %q", proxy)
+		}
 	}
 
 	return cfg, nil
 }
 
+// LevelSchemeRule reads the numeric levels of sources matching Sources, a
+// glob pattern as in path.Match, in Scheme.
+type LevelSchemeRule struct {
+	Sources string
+	Scheme  parser.LevelScheme
+}
+
+// loadLevelSchemes reads LEVEL_SCHEMES, a list of source=scheme pairs such
+// as "api-*=pino,collector=otel".
+func loadLevelSchemes() ([]LevelSchemeRule, error) {
+	var rules []LevelSchemeRule
+	for _, item := range getEnvAsSlice("LEVEL_SCHEMES", nil) {
+		sources, name, found := strings.Cut(item, "=")
+		if !found || sources == "" {
+			return nil, fmt.Errorf("invalid LEVEL_SCHEMES entry %q", item)
+		}
+		if _, err := path.Match(sources, ""); err != nil {
+			return nil, fmt.Errorf("invalid LEVEL_SCHEMES entry %q: %w", item, err)
+		}
+		scheme, err := parser.ParseLevelScheme(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LEVEL_SCHEMES entry %q: %w", item, err)
+		}
+		rules = append(rules, LevelSchemeRule{Sources: sources, Scheme: scheme})
+	}
+	return rules, nil
+}
+
+// loadSinks reads the sinks named in SINKS. Each defaults to the global
+// batching settings; the primary VictoriaLogs sink spools failed batches
+// when SPOOL_ENABLED is set, the others drop them.
+func loadSinks(cfg *Config) ([]SinkConfig, error) {
+	var sinks []SinkConfig
+	seen := make(map[string]bool)
+	for _, name := range getEnvAsSlice("SINKS", []string{SinkVictoriaLogs}) {
+		switch name {
+		case SinkVictoriaLogs, SinkSecondaryVictoriaLogs, SinkFile, SinkStdout:
+		default:
+			return nil, fmt.Errorf("unknown sink %q in SINKS", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate sink %q in SINKS", name)
+		}
+		seen[name] = true
+
+		onFailure := sink.PolicyDrop
+		if name == SinkVictoriaLogs && cfg.SpoolEnabled {
+			onFailure = sink.PolicySpool
+		}
+
+		prefix := "SINK_" + strings.ToUpper(name) + "_"
+		policy, err := sink.ParsePolicy(getEnv(prefix+"ON_FAILURE", string(onFailure)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %sON_FAILURE: %w", prefix, err)
+		}
+
+		sinks = append(sinks, SinkConfig{
+			Name:          name,
+			BatchSize:     getEnvAsInt(prefix+"BATCH_SIZE", cfg.BatchSize),
+			BufferSize:    getEnvAsInt(prefix+"BUFFER_SIZE", cfg.BufferSize),
+			FlushInterval: getEnvAsDuration(prefix+"FLUSH_INTERVAL", cfg.FlushInterval),
+			Workers:       getEnvAsInt(prefix+"FLUSH_WORKERS", cfg.FlushWorkers),
+			OnFailure:     policy,
+		})
+	}
+
+	if seen[SinkSecondaryVictoriaLogs] {
+		cfg.SecondaryVictoriaLogsURL = getEnv("SECONDARY_VICTORIA_LOGS_URL", "")
+		if cfg.SecondaryVictoriaLogsURL == "" {
+			return nil, fmt.Errorf("SECONDARY_VICTORIA_LOGS_URL is required for the %s sink", SinkSecondaryVictoriaLogs)
+		}
+	}
+	return sinks, nil
+}
+
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -42,3 +336,45 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvAsInt64(key string, defaultValue int64) int64 {
+	if value := os.Getenv(key); value != "" {
+		if intValue, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return intValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsSlice(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+	}
+	return defaultValue
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadAuthDefaults(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !cfg.AuthEnabled {
+		t.Error("Expected API key authentication to be enabled by default")
+	}
+	if cfg.APIKeyCacheTTL != time.Minute || cfg.APIKeyTouchInterval != 30*time.Second {
+		t.Errorf("Unexpected API key defaults: %v %v", cfg.APIKeyCacheTTL, cfg.APIKeyTouchInterval)
+	}
+
+	t.Setenv("AUTH_ENABLED", "false")
+	t.Setenv("DATABASE_URL", "postgres://lograil")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.AuthEnabled || cfg.DatabaseURL != "postgres://lograil" {
+		t.Errorf("Expected AUTH_ENABLED and DATABASE_URL to be read, got %v %q", cfg.AuthEnabled, cfg.DatabaseURL)
+	}
+}
+
+func TestLoadVictoriaLogsResilienceDefaults(t *testing.T) {
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.VictoriaLogsTimeout != 30*time.Second || cfg.WriteMaxRetries != 3 ||
+		cfg.WriteRetryBaseDelay != 100*time.Millisecond || cfg.WriteRetryMaxDelay != 5*time.Second {
+		t.Errorf("Unexpected retry defaults: %+v", cfg)
+	}
+	if cfg.BreakerThreshold != 5 || cfg.BreakerCooldown != 30*time.Second {
+		t.Errorf("Unexpected circuit breaker defaults: %d %v", cfg.BreakerThreshold, cfg.BreakerCooldown)
+	}
+
+	t.Setenv("VICTORIA_LOGS_BREAKER_THRESHOLD", "0")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.BreakerThreshold != 0 {
+		t.Errorf("Expected VICTORIA_LOGS_BREAKER_THRESHOLD=0 to disable the breaker, got %d", cfg.BreakerThreshold)
+	}
+}
+
+func TestLoadLevelSchemes(t *testing.T) {
+	t.Setenv("LEVEL_SCHEMES", "api-*=pino, collector=otel")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if len(cfg.LevelSchemes) != 2 || cfg.LevelSchemes[0].Sources != "api-*" || cfg.LevelSchemes[1].Scheme != "otel" {
+		t.Errorf("Unexpected level schemes: %+v", cfg.LevelSchemes)
+	}
+
+	for _, value := range []string{"api-*", "api-*=log4j", "[=pino"} {
+		t.Setenv("LEVEL_SCHEMES", value)
+		if _, err := Load(); err == nil {
+			t.Errorf("Expected LEVEL_SCHEMES=%q to be rejected", value)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"entgo.io/ent/dialect"
+	entsql "entgo.io/ent/dialect/sql"
+	"github.com/bizjs/Lograil/pkg/data"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// NewEntClient opens the control-plane metadata database read by the
+// ingestion service, e.g. to verify API keys.
+func NewEntClient(databaseURL string) (*data.Client, error) {
+	databaseURL = strings.TrimPrefix(databaseURL, "sqlite://")
+	if databaseURL == "" {
+		databaseURL = "file:lograil.db?cache=shared&_fk=1"
+	}
+
+	db, err := sql.Open("sqlite3", databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database connection: %w", err)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	drv := entsql.OpenDB(dialect.SQLite, db)
+	return data.NewClient(data.Driver(drv)), nil
+}
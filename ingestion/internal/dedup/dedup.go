@@ -0,0 +1,185 @@
+package dedup
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// State is the outcome of Begin.
+type State int
+
+const (
+	// Started means the key was not seen in the window and is now in
+	// progress.
+	Started State = iota
+	// Replay means the key completed and its Result should be returned.
+	Replay
+	// InProgress means another request with the key has not completed.
+	InProgress
+	// Mismatch means the key was used for a request with a different
+	// fingerprint.
+	Mismatch
+)
+
+// Options configures a Store.
+type Options struct {
+	// Window is how long keys are remembered.
+	Window time.Duration
+	// MaxKeys bounds the number of keys held; the oldest are evicted first.
+	// Requests in progress are never evicted, so they may exceed it.
+	MaxKeys int
+}
+
+// Result is a stored response, returned for replayed requests.
+type Result struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// Stats counts deduplicated traffic.
+type Stats struct {
+	Keys       int   `json:"keys"`
+	Replays    int64 `json:"replays"`
+	Duplicates int64 `json:"duplicates"`
+}
+
+// Store remembers request idempotency keys and event IDs for a time
+// window. It is held in memory, so deduplication is per ingestion node.
+type Store struct {
+	opts Options
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// order holds entries by insertion, which is also expiry order.
+	order      *list.List
+	replays    int64
+	duplicates int64
+}
+
+type entry struct {
+	key         string
+	fingerprint string
+	expires     time.Time
+	done        bool
+	result      Result
+}
+
+func New(opts Options) *Store {
+	if opts.MaxKeys <= 0 {
+		opts.MaxKeys = 100000
+	}
+	return &Store{
+		opts:    opts,
+		now:     time.Now,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// Begin starts a request with an idempotency key and a fingerprint of the
+// request, such as a hash of its body. For Started, the caller must call
+// Complete or Abort once the request finishes.
+func (s *Store) Begin(key, fingerprint string) (Result, State) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e := s.lookup(key); e != nil {
+		if e.fingerprint != fingerprint {
+			return Result{}, Mismatch
+		}
+		if !e.done {
+			return Result{}, InProgress
+		}
+		s.replays++
+		return e.result, Replay
+	}
+
+	s.insert(&entry{key: key, fingerprint: fingerprint})
+	return Result{}, Started
+}
+
+// Complete stores the result of a request started with Begin.
+func (s *Store) Complete(key string, result Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e := s.lookup(key); e != nil {
+		e.done = true
+		e.result = result
+	}
+}
+
+// Abort forgets a request started with Begin, so that it can be retried.
+func (s *Store) Abort(key string) {
+	s.Release(key)
+}
+
+// Claim records an event ID, reporting false if it was already seen within
+// the window.
+func (s *Store) Claim(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lookup(key) != nil {
+		s.duplicates++
+		return false
+	}
+	s.insert(&entry{key: key, done: true})
+	return true
+}
+
+// Release forgets a key, e.g. an event ID whose entry failed to enqueue.
+func (s *Store) Release(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if element, ok := s.entries[key]; ok {
+		s.order.Remove(element)
+		delete(s.entries, key)
+	}
+}
+
+// Stats returns the number of keys held and the deduplicated traffic.
+func (s *Store) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return Stats{Keys: len(s.entries), Replays: s.replays, Duplicates: s.duplicates}
+}
+
+// lookup returns the live entry for key after evicting expired ones.
+func (s *Store) lookup(key string) *entry {
+	now := s.now()
+	for front := s.order.Front(); front != nil; front = s.order.Front() {
+		e := front.Value.(*entry)
+		if now.Before(e.expires) {
+			break
+		}
+		s.order.Remove(front)
+		delete(s.entries, e.key)
+	}
+
+	if element, ok := s.entries[key]; ok {
+		return element.Value.(*entry)
+	}
+	return nil
+}
+
+// insert adds e, evicting the oldest completed entries beyond MaxKeys.
+func (s *Store) insert(e *entry) {
+	element := s.order.Front()
+	for s.order.Len() >= s.opts.MaxKeys && element != nil {
+		next := element.Next()
+		if old := element.Value.(*entry); old.done {
+			s.order.Remove(element)
+			delete(s.entries, old.key)
+		}
+		element = next
+	}
+
+	e.expires = s.now().Add(s.opts.Window)
+	s.entries[e.key] = s.order.PushBack(e)
+}
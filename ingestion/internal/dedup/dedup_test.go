@@ -0,0 +1,79 @@
+package dedup
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStoreRequests(t *testing.T) {
+	now := time.Unix(0, 0)
+	s := New(Options{Window: time.Minute})
+	s.now = func() time.Time { return now }
+
+	if _, state := s.Begin("a", "x"); state != Started {
+		t.Fatalf("Expected Started, got %v", state)
+	}
+	if _, state := s.Begin("a", "x"); state != InProgress {
+		t.Fatalf("Expected InProgress, got %v", state)
+	}
+
+	s.Complete("a", Result{Status: 202, Body: []byte("ok")})
+	if _, state := s.Begin("a", "y"); state != Mismatch {
+		t.Fatalf("Expected Mismatch for a different fingerprint, got %v", state)
+	}
+	result, state := s.Begin("a", "x")
+	if state != Replay || result.Status != 202 || string(result.Body) != "ok" {
+		t.Fatalf("Expected replay of the stored result, got %v %+v", state, result)
+	}
+
+	s.Begin("b", "x")
+	s.Abort("b")
+	if _, state := s.Begin("b", "x"); state != Started {
+		t.Errorf("Aborted key should start again, got %v", state)
+	}
+
+	now = now.Add(time.Minute)
+	if _, state := s.Begin("a", "x"); state != Started {
+		t.Errorf("Expired key should start again, got %v", state)
+	}
+	if stats := s.Stats(); stats.Replays != 1 {
+		t.Errorf("Expected 1 replay, got %+v", stats)
+	}
+}
+
+func TestStoreEvents(t *testing.T) {
+	s := New(Options{Window: time.Hour, MaxKeys: 2})
+
+	if !s.Claim("1") || s.Claim("1") {
+		t.Fatal("Expected the second claim of an event to be a duplicate")
+	}
+
+	s.Release("1")
+	if !s.Claim("1") {
+		t.Error("Released event should be claimable again")
+	}
+
+	s.Claim("2")
+	s.Claim("3")
+	if stats := s.Stats(); stats.Keys != 2 || stats.Duplicates != 1 {
+		t.Errorf("Expected 2 keys and 1 duplicate, got %+v", stats)
+	}
+	if !s.Claim("1") {
+		t.Error("Oldest event should have been evicted at MaxKeys")
+	}
+}
+
+func TestStoreKeepsRequestsInProgress(t *testing.T) {
+	s := New(Options{Window: time.Hour, MaxKeys: 2})
+
+	s.Begin("a", "x")
+	s.Claim("1")
+	s.Claim("2")
+
+	if _, state := s.Begin("a", "x"); state != InProgress {
+		t.Errorf("Request in progress should not be evicted, got %v", state)
+	}
+	if !s.Claim("1") {
+		t.Error("Oldest completed key should have been evicted instead")
+	}
+}
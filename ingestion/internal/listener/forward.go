@@ -0,0 +1,111 @@
+package listener
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/bizjs/Lograil/ingestion/internal/buffer"
+	"github.com/bizjs/Lograil/ingestion/internal/parser"
+)
+
+// ForwardOptions configures a ForwardServer.
+type ForwardOptions struct {
+	// Addr is the TCP listen address.
+	Addr string
+	// ProjectID is assigned to every received entry, since the Forward
+	// protocol is used without credentials.
+	ProjectID int
+	// MaxMessageSize bounds packed and decompressed entry payloads.
+	MaxMessageSize int
+}
+
+// ForwardServer receives the Fluentd Forward protocol (msgpack over TCP),
+// as sent by the Fluentd and Fluent Bit forward outputs.
+type ForwardServer struct {
+	opts   ForwardOptions
+	ingest IngestFunc
+
+	tcp *tcpServer
+}
+
+func NewForwardServer(opts ForwardOptions, ingest IngestFunc) *ForwardServer {
+	if opts.MaxMessageSize <= 0 {
+		opts.MaxMessageSize = 8 * 1024 * 1024
+	}
+
+	return &ForwardServer{
+		opts:   opts,
+		ingest: ingest,
+	}
+}
+
+// Start opens the listener and serves it in the background.
+func (s *ForwardServer) Start() error {
+	tcp, err := listenTCP(s.opts.Addr, s.serveConn)
+	if err != nil {
+		return fmt.Errorf("failed to listen on forward TCP %s: %w", s.opts.Addr, err)
+	}
+	s.tcp = tcp
+	return nil
+}
+
+// Close stops the listener and waits for open connections to finish.
+func (s *ForwardServer) Close() error {
+	if s.tcp != nil {
+		s.tcp.Close()
+	}
+	return nil
+}
+
+func (s *ForwardServer) serveConn(conn net.Conn) {
+	dec := msgpack.NewDecoder(bufio.NewReaderSize(conn, 64*1024))
+	enc := msgpack.NewEncoder(conn)
+
+	for {
+		msg, err := parser.DecodeForward(dec, s.opts.MaxMessageSize, time.Now())
+		if err != nil {
+			// The stream cannot be resynchronised after a malformed message
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				log.Printf("Forward connection from %s closed: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		for i := range msg.Entries {
+			msg.Entries[i].ProjectID = s.opts.ProjectID
+		}
+
+		if len(msg.Entries) > 0 {
+			// Ingest buffers large chunks piecewise, so any error left is
+			// either transient or permanent for the whole chunk
+			if err := s.ingest(msg.Entries); err != nil {
+				if retryable(err) {
+					// Without an ack the client retries the chunk
+					log.Printf("Withholding ack for %d forward entries with tag %q: %v", len(msg.Entries), msg.Tag, err)
+					continue
+				}
+				log.Printf("Dropping %d forward entries with tag %q: %v", len(msg.Entries), msg.Tag, err)
+			}
+		}
+
+		if msg.Chunk != "" {
+			if err := enc.Encode(map[string]string{"ack": msg.Chunk}); err != nil {
+				log.Printf("Forward ack to %s failed: %v", conn.RemoteAddr(), err)
+				return
+			}
+		}
+	}
+}
+
+// retryable reports whether a chunk that failed with err may succeed when
+// sent again.
+func retryable(err error) bool {
+	return errors.Is(err, buffer.ErrFull) || errors.Is(err, buffer.ErrClosed)
+}
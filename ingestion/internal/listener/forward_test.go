@@ -0,0 +1,98 @@
+package listener
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/bizjs/Lograil/ingestion/internal/buffer"
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+)
+
+func TestForwardServerAck(t *testing.T) {
+	received := make(chan []storage.LogEntry, 1)
+
+	server := NewForwardServer(ForwardOptions{Addr: "127.0.0.1:0", ProjectID: 3}, func(logs []storage.LogEntry) error {
+		received <- logs
+		return nil
+	})
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.tcp.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+
+	message := []interface{}{
+		"kube.api",
+		[]interface{}{[]interface{}{1700000000, map[string]interface{}{"log": "hello"}}},
+		map[string]interface{}{"chunk": "abc"},
+	}
+	if err := msgpack.NewEncoder(conn).Encode(message); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	var ack map[string]string
+	if err := msgpack.NewDecoder(conn).Decode(&ack); err != nil {
+		t.Fatalf("Reading ack failed: %v", err)
+	}
+	if ack["ack"] != "abc" {
+		t.Errorf("Expected ack for chunk abc, got %v", ack)
+	}
+
+	logs := <-received
+	if len(logs) != 1 || logs[0].Source != "kube.api" || logs[0].Message != "hello" || logs[0].ProjectID != 3 {
+		t.Errorf("Unexpected entries: %+v", logs)
+	}
+}
+
+func TestForwardServerAckOnlyAfterRetryableErrors(t *testing.T) {
+	errs := make(chan error, 2)
+	errs <- buffer.ErrFull
+	errs <- errors.New("entry rejected")
+
+	server := NewForwardServer(ForwardOptions{Addr: "127.0.0.1:0"}, func(logs []storage.LogEntry) error {
+		return <-errs
+	})
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.tcp.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second))
+
+	enc := msgpack.NewEncoder(conn)
+	for _, chunk := range []string{"full", "rejected"} {
+		message := []interface{}{
+			"app",
+			[]interface{}{[]interface{}{1700000000, map[string]interface{}{"log": "hello"}}},
+			map[string]interface{}{"chunk": chunk},
+		}
+		if err := enc.Encode(message); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	// The chunk refused by a full buffer is left for the client to retry,
+	// while one that can never succeed is acked
+	var ack map[string]string
+	if err := msgpack.NewDecoder(conn).Decode(&ack); err != nil {
+		t.Fatalf("Reading ack failed: %v", err)
+	}
+	if ack["ack"] != "rejected" {
+		t.Errorf("Expected only chunk rejected to be acked, got %v", ack)
+	}
+}
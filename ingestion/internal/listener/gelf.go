@@ -0,0 +1,289 @@
+package listener
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/bizjs/Lograil/ingestion/internal/parser"
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+)
+
+const (
+	// gelfMaxChunks is the largest sequence count allowed by the specification.
+	gelfMaxChunks = 128
+	// gelfMaxPending bounds the number of partially received messages.
+	gelfMaxPending = 1024
+)
+
+var gelfChunkMagic = []byte{0x1e, 0x0f}
+
+// GELFOptions configures a GELFServer.
+type GELFOptions struct {
+	// UDPAddr and TCPAddr are listen addresses; empty disables the listener.
+	UDPAddr string
+	TCPAddr string
+	// ProjectID is assigned to every received entry, since GELF carries no
+	// credentials.
+	ProjectID int
+	// MaxMessageSize bounds a reassembled or decompressed message.
+	MaxMessageSize int
+	// ChunkTimeout is how long the chunks of an incomplete UDP message are
+	// kept before they are discarded.
+	ChunkTimeout time.Duration
+}
+
+// GELFServer receives GELF messages over UDP, optionally chunked and
+// compressed with gzip or zlib, and over null-delimited TCP.
+type GELFServer struct {
+	opts   GELFOptions
+	ingest IngestFunc
+	chunks *chunkAssembler
+
+	udp  net.PacketConn
+	tcp  *tcpServer
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func NewGELFServer(opts GELFOptions, ingest IngestFunc) *GELFServer {
+	if opts.MaxMessageSize <= 0 {
+		opts.MaxMessageSize = 1024 * 1024
+	}
+	if opts.ChunkTimeout <= 0 {
+		opts.ChunkTimeout = 5 * time.Second
+	}
+
+	return &GELFServer{
+		opts:   opts,
+		ingest: ingest,
+		chunks: newChunkAssembler(opts.MaxMessageSize, opts.ChunkTimeout),
+		done:   make(chan struct{}),
+	}
+}
+
+// Start opens the configured listeners and serves them in the background.
+func (s *GELFServer) Start() error {
+	if s.opts.UDPAddr != "" {
+		udp, err := net.ListenPacket("udp", s.opts.UDPAddr)
+		if err != nil {
+			return fmt.Errorf("failed to listen on GELF UDP %s: %w", s.opts.UDPAddr, err)
+		}
+		s.udp = udp
+
+		s.wg.Add(2)
+		go s.serveUDP()
+		go s.expireChunks()
+	}
+
+	if s.opts.TCPAddr != "" {
+		tcp, err := listenTCP(s.opts.TCPAddr, s.serveConn)
+		if err != nil {
+			s.Close()
+			return fmt.Errorf("failed to listen on GELF TCP %s: %w", s.opts.TCPAddr, err)
+		}
+		s.tcp = tcp
+	}
+
+	return nil
+}
+
+// Close stops the listeners and waits for open connections to finish.
+func (s *GELFServer) Close() error {
+	select {
+	case <-s.done:
+	default:
+		close(s.done)
+	}
+	if s.udp != nil {
+		s.udp.Close()
+	}
+	if s.tcp != nil {
+		s.tcp.Close()
+	}
+
+	s.wg.Wait()
+	return nil
+}
+
+func (s *GELFServer) serveUDP() {
+	defer s.wg.Done()
+
+	buf := make([]byte, 65536)
+	for {
+		n, _, err := s.udp.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("GELF UDP read failed: %v", err)
+			continue
+		}
+
+		datagram := buf[:n]
+		if !bytes.HasPrefix(datagram, gelfChunkMagic) {
+			s.handle(datagram)
+			continue
+		}
+
+		message, err := s.chunks.add(datagram, time.Now())
+		if err != nil {
+			log.Printf("Dropping GELF chunk: %v", err)
+			continue
+		}
+		if message != nil {
+			s.handle(message)
+		}
+	}
+}
+
+func (s *GELFServer) expireChunks() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.opts.ChunkTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			if expired := s.chunks.expire(now); expired > 0 {
+				log.Printf("Dropped %d incomplete GELF messages after %s", expired, s.opts.ChunkTimeout)
+			}
+		}
+	}
+}
+
+func (s *GELFServer) serveConn(conn net.Conn) {
+	reader := bufio.NewReaderSize(conn, 64*1024)
+	for {
+		frame, err := readDelimited(reader, 0, s.opts.MaxMessageSize)
+		frame = bytes.TrimRight(frame, "\x00\r\n")
+		if len(frame) > 0 {
+			s.handle(frame)
+		}
+		if err != nil {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				log.Printf("GELF TCP connection from %s closed: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+	}
+}
+
+func (s *GELFServer) handle(payload []byte) {
+	entry, err := parser.ParseGELF(payload, s.opts.MaxMessageSize, time.Now())
+	if err != nil {
+		log.Printf("Dropping unparsable GELF message: %v", err)
+		return
+	}
+	entry.ProjectID = s.opts.ProjectID
+
+	if err := s.ingest([]storage.LogEntry{entry}); err != nil {
+		log.Printf("Dropping GELF message: %v", err)
+	}
+}
+
+// chunkAssembler reassembles chunked GELF datagrams: 2 magic bytes, an
+// 8-byte message ID, the sequence number and the sequence count, followed
+// by the chunk payload.
+type chunkAssembler struct {
+	maxSize int
+	timeout time.Duration
+
+	mu      sync.Mutex
+	pending map[[8]byte]*chunkedMessage
+}
+
+type chunkedMessage struct {
+	parts    [][]byte
+	received int
+	size     int
+	started  time.Time
+}
+
+func newChunkAssembler(maxSize int, timeout time.Duration) *chunkAssembler {
+	return &chunkAssembler{
+		maxSize: maxSize,
+		timeout: timeout,
+		pending: make(map[[8]byte]*chunkedMessage),
+	}
+}
+
+// add stores one chunk and returns the complete message once all of its
+// chunks have arrived.
+func (a *chunkAssembler) add(datagram []byte, now time.Time) ([]byte, error) {
+	if len(datagram) < 12 {
+		return nil, fmt.Errorf("chunk of %d bytes is too short", len(datagram))
+	}
+
+	var id [8]byte
+	copy(id[:], datagram[2:10])
+	seq, count := int(datagram[10]), int(datagram[11])
+	if count == 0 || count > gelfMaxChunks || seq >= count {
+		return nil, fmt.Errorf("invalid chunk %d of %d", seq, count)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	msg, ok := a.pending[id]
+	if ok && now.Sub(msg.started) > a.timeout {
+		delete(a.pending, id)
+		ok = false
+	}
+	if !ok {
+		if len(a.pending) >= gelfMaxPending {
+			return nil, fmt.Errorf("too many incomplete messages")
+		}
+		msg = &chunkedMessage{parts: make([][]byte, count), started: now}
+		a.pending[id] = msg
+	}
+
+	if len(msg.parts) != count {
+		delete(a.pending, id)
+		return nil, fmt.Errorf("chunk count changed from %d to %d", len(msg.parts), count)
+	}
+	if msg.parts[seq] != nil {
+		return nil, nil
+	}
+
+	payload := datagram[12:]
+	msg.size += len(payload)
+	if msg.size > a.maxSize {
+		delete(a.pending, id)
+		return nil, fmt.Errorf("message exceeds %d bytes", a.maxSize)
+	}
+	msg.parts[seq] = make([]byte, len(payload))
+	copy(msg.parts[seq], payload)
+	msg.received++
+
+	if msg.received < count {
+		return nil, nil
+	}
+
+	delete(a.pending, id)
+	return bytes.Join(msg.parts, nil), nil
+}
+
+// expire discards incomplete messages older than the timeout.
+func (a *chunkAssembler) expire(now time.Time) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	expired := 0
+	for id, msg := range a.pending {
+		if now.Sub(msg.started) > a.timeout {
+			delete(a.pending, id)
+			expired++
+		}
+	}
+	return expired
+}
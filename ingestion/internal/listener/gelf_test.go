@@ -0,0 +1,76 @@
+package listener
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+)
+
+func gelfChunk(id byte, seq, count int, payload string) []byte {
+	chunk := []byte{0x1e, 0x0f, id, 0, 0, 0, 0, 0, 0, 0, byte(seq), byte(count)}
+	return append(chunk, payload...)
+}
+
+func TestChunkAssembler(t *testing.T) {
+	assembler := newChunkAssembler(1024, time.Second)
+	now := time.Now()
+
+	if msg, err := assembler.add(gelfChunk(1, 1, 2, `"x"}`), now); msg != nil || err != nil {
+		t.Fatalf("Expected incomplete message, got %q, %v", msg, err)
+	}
+	msg, err := assembler.add(gelfChunk(1, 0, 2, `{"short_message":`), now)
+	if err != nil || string(msg) != `{"short_message":"x"}` {
+		t.Fatalf("Unexpected reassembled message %q, %v", msg, err)
+	}
+
+	// Chunks of an expired message are discarded
+	assembler.add(gelfChunk(2, 0, 2, "a"), now)
+	if expired := assembler.expire(now.Add(2 * time.Second)); expired != 1 {
+		t.Errorf("Expected 1 expired message, got %d", expired)
+	}
+	if msg, _ := assembler.add(gelfChunk(2, 1, 2, "b"), now.Add(2*time.Second)); msg != nil {
+		t.Errorf("Expected no message after expiry, got %q", msg)
+	}
+
+	if _, err := assembler.add(gelfChunk(3, 2, 2, "a"), now); err == nil {
+		t.Error("Expected an error for a sequence number past the count")
+	}
+}
+
+func TestGELFServerTCP(t *testing.T) {
+	received := make(chan storage.LogEntry, 2)
+
+	server := NewGELFServer(GELFOptions{TCPAddr: "127.0.0.1:0", ProjectID: 4}, func(logs []storage.LogEntry) error {
+		for _, l := range logs {
+			received <- l
+		}
+		return nil
+	})
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.tcp.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	payload := `{"short_message":"first","host":"h"}` + "\x00" + `{"short_message":"second","host":"h"}` + "\x00"
+	if _, err := conn.Write([]byte(payload)); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	conn.Close()
+
+	for _, want := range []string{"first", "second"} {
+		select {
+		case entry := <-received:
+			if entry.Message != want || entry.ProjectID != 4 {
+				t.Errorf("Unexpected entry: %+v", entry)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for %q", want)
+		}
+	}
+}
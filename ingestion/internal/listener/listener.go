@@ -0,0 +1,113 @@
+package listener
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+)
+
+// IngestFunc hands parsed log entries to the ingestion pipeline.
+type IngestFunc func([]storage.LogEntry) error
+
+// tcpServer accepts connections and serves each in its own goroutine,
+// tracking them so that Close can terminate and wait for all of them.
+type tcpServer struct {
+	listener net.Listener
+	serve    func(net.Conn)
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+}
+
+func listenTCP(addr string, serve func(net.Conn)) (*tcpServer, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &tcpServer{
+		listener: ln,
+		serve:    serve,
+		conns:    make(map[net.Conn]struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.acceptLoop()
+
+	return s, nil
+}
+
+// Addr returns the bound address.
+func (s *tcpServer) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Close stops accepting, closes open connections and waits for handlers.
+func (s *tcpServer) Close() {
+	s.listener.Close()
+
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+func (s *tcpServer) acceptLoop() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("TCP accept on %s failed: %v", s.listener.Addr(), err)
+			time.Sleep(100 * time.Millisecond)
+			continue
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer func() {
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+				conn.Close()
+			}()
+
+			s.serve(conn)
+		}()
+	}
+}
+
+// readDelimited reads up to and including delim, failing once the frame
+// exceeds maxSize. A final frame without delimiter is returned with io.EOF.
+func readDelimited(reader *bufio.Reader, delim byte, maxSize int) ([]byte, error) {
+	var frame []byte
+	for {
+		chunk, err := reader.ReadSlice(delim)
+		if len(frame)+len(chunk) > maxSize {
+			return nil, fmt.Errorf("message exceeds %d bytes", maxSize)
+		}
+		frame = append(frame, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		return frame, err
+	}
+}
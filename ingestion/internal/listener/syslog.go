@@ -0,0 +1,191 @@
+package listener
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bizjs/Lograil/ingestion/internal/parser"
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+)
+
+// SyslogOptions configures a SyslogServer.
+type SyslogOptions struct {
+	// UDPAddr and TCPAddr are listen addresses; empty disables the listener.
+	UDPAddr string
+	TCPAddr string
+	// ProjectID is assigned to every received entry, since syslog carries
+	// no credentials.
+	ProjectID int
+	// MaxMessageSize bounds a single message.
+	MaxMessageSize int
+}
+
+// SyslogServer receives RFC 5424 and RFC 3164 messages over UDP and TCP.
+// TCP streams may use octet-counted (RFC 6587) or newline framing.
+type SyslogServer struct {
+	opts   SyslogOptions
+	ingest IngestFunc
+
+	udp net.PacketConn
+	tcp *tcpServer
+	wg  sync.WaitGroup
+}
+
+func NewSyslogServer(opts SyslogOptions, ingest IngestFunc) *SyslogServer {
+	if opts.MaxMessageSize <= 0 {
+		opts.MaxMessageSize = 64 * 1024
+	}
+
+	return &SyslogServer{
+		opts:   opts,
+		ingest: ingest,
+	}
+}
+
+// Start opens the configured listeners and serves them in the background.
+func (s *SyslogServer) Start() error {
+	if s.opts.UDPAddr != "" {
+		udp, err := net.ListenPacket("udp", s.opts.UDPAddr)
+		if err != nil {
+			return fmt.Errorf("failed to listen on syslog UDP %s: %w", s.opts.UDPAddr, err)
+		}
+		s.udp = udp
+
+		s.wg.Add(1)
+		go s.serveUDP()
+	}
+
+	if s.opts.TCPAddr != "" {
+		tcp, err := listenTCP(s.opts.TCPAddr, s.serveConn)
+		if err != nil {
+			s.Close()
+			return fmt.Errorf("failed to listen on syslog TCP %s: %w", s.opts.TCPAddr, err)
+		}
+		s.tcp = tcp
+	}
+
+	return nil
+}
+
+// Close stops the listeners and waits for open connections to finish.
+func (s *SyslogServer) Close() error {
+	if s.udp != nil {
+		s.udp.Close()
+	}
+	if s.tcp != nil {
+		s.tcp.Close()
+	}
+
+	s.wg.Wait()
+	return nil
+}
+
+func (s *SyslogServer) serveUDP() {
+	defer s.wg.Done()
+
+	buf := make([]byte, s.opts.MaxMessageSize)
+	for {
+		n, _, err := s.udp.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Printf("Syslog UDP read failed: %v", err)
+			continue
+		}
+
+		s.handle(buf[:n])
+	}
+}
+
+func (s *SyslogServer) serveConn(conn net.Conn) {
+	reader := bufio.NewReaderSize(conn, 64*1024)
+	for {
+		frame, err := s.readFrame(reader)
+		if len(frame) > 0 {
+			s.handle(frame)
+		}
+		if err != nil {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				log.Printf("Syslog TCP connection from %s closed: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+	}
+}
+
+// maxOctetCountLength bounds the length prefix of an octet-counted frame.
+const maxOctetCountLength = 10
+
+// readFrame reads one message. A frame starting with a digit is
+// octet-counted ("LEN SP MSG"); anything else is terminated by a newline.
+func (s *SyslogServer) readFrame(reader *bufio.Reader) ([]byte, error) {
+	first, err := reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if first[0] >= '0' && first[0] <= '9' {
+		lengthField, err := readOctetCount(reader)
+		if err != nil {
+			return nil, err
+		}
+		length, err := strconv.Atoi(lengthField)
+		if err != nil || length <= 0 || length > s.opts.MaxMessageSize {
+			return nil, fmt.Errorf("invalid octet count %q", lengthField)
+		}
+
+		frame := make([]byte, length)
+		if _, err := io.ReadFull(reader, frame); err != nil {
+			return nil, err
+		}
+		return frame, nil
+	}
+
+	frame, err := readDelimited(reader, '\n', s.opts.MaxMessageSize)
+	return bytes.TrimRight(frame, "\r\n"), err
+}
+
+// readOctetCount reads the length prefix of an octet-counted frame and
+// the space after it. The prefix is read byte by byte, so that a stream of
+// digits cannot grow it without limit, and a short frame is not held back
+// waiting for more bytes.
+func readOctetCount(reader *bufio.Reader) (string, error) {
+	var prefix []byte
+	for len(prefix) <= maxOctetCountLength {
+		c, err := reader.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		if c == ' ' {
+			return string(prefix), nil
+		}
+		prefix = append(prefix, c)
+	}
+	return "", fmt.Errorf("invalid octet count %q", prefix)
+}
+
+func (s *SyslogServer) handle(msg []byte) {
+	if len(bytes.TrimSpace(msg)) == 0 {
+		return
+	}
+
+	entry, err := parser.ParseSyslog(msg, time.Now())
+	if err != nil {
+		log.Printf("Dropping unparsable syslog message: %v", err)
+		return
+	}
+	entry.ProjectID = s.opts.ProjectID
+
+	if err := s.ingest([]storage.LogEntry{entry}); err != nil {
+		log.Printf("Dropping syslog message: %v", err)
+	}
+}
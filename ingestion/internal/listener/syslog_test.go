@@ -0,0 +1,81 @@
+package listener
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+)
+
+func TestSyslogServerTCPFraming(t *testing.T) {
+	var mu sync.Mutex
+	var messages []string
+
+	server := NewSyslogServer(SyslogOptions{TCPAddr: "127.0.0.1:0", ProjectID: 5}, func(logs []storage.LogEntry) error {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, l := range logs {
+			if l.ProjectID != 5 {
+				t.Errorf("Expected project 5, got %d", l.ProjectID)
+			}
+			messages = append(messages, l.Message)
+		}
+		return nil
+	})
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.tcp.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+
+	octetCounted := "<14>1 - host app - - - multi\nline"
+	payload := "<14>Jan  1 00:00:00 host app: first\n" +
+		strconv.Itoa(len(octetCounted)) + " " + octetCounted
+	if _, err := conn.Write([]byte(payload)); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	conn.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		n := len(messages)
+		mu.Unlock()
+		if n == 2 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(messages) != 2 || messages[0] != "first" || messages[1] != "multi\nline" {
+		t.Errorf("Unexpected messages: %q", messages)
+	}
+}
+
+func TestReadOctetCount(t *testing.T) {
+	count, err := readOctetCount(bufio.NewReader(strings.NewReader("1234567890 msg")))
+	if err != nil || count != "1234567890" {
+		t.Errorf("Expected a ten digit count, got %q: %v", count, err)
+	}
+
+	// A run of digits fails once it cannot be a count, without being read
+	// to the end
+	reader := bufio.NewReader(strings.NewReader(strings.Repeat("1", 1024)))
+	if _, err := readOctetCount(reader); err == nil || strings.Contains(err.Error(), "EOF") {
+		t.Errorf("Expected an invalid octet count, got %v", err)
+	}
+	if reader.Buffered() == 0 {
+		t.Error("Expected the rest of the digits to be left unread")
+	}
+}
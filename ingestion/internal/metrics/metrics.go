@@ -0,0 +1,267 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bizjs/Lograil/ingestion/internal/ratelimit"
+	"github.com/bizjs/Lograil/ingestion/internal/sink"
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "lograil_ingestion"
+
+// OtherSource replaces source labels once MaxSources project/source pairs
+// have been seen, so that clients cannot create unbounded series.
+const OtherSource = "_other"
+
+// MaxSources bounds the project/source pairs exported with their own
+// series.
+const MaxSources = 1000
+
+// Metrics holds the ingestion service's Prometheus metrics in a registry of
+// its own.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	entries         *prometheus.CounterVec
+	bytes           *prometheus.CounterVec
+	rejected        *prometheus.CounterVec
+	writeDuration   *prometheus.HistogramVec
+	writeErrors     *prometheus.CounterVec
+	batchSize       *prometheus.HistogramVec
+
+	mu      sync.Mutex
+	sources map[source]struct{}
+}
+
+type source struct {
+	project int
+	name    string
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route, method and status.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route, method and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		entries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "entries_accepted_total",
+			Help:      "Log entries accepted into the buffer by project and source.",
+		}, []string{"project", "source"}),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "message_bytes_accepted_total",
+			Help:      "Message bytes of accepted log entries by project and source.",
+		}, []string{"project", "source"}),
+		rejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "entries_rejected_total",
+			Help:      "Log entries the buffer refused by reason.",
+		}, []string{"reason"}),
+		writeDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "sink_write_duration_seconds",
+			Help:      "Latency of batch writes to storage by sink, including retries.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"sink"}),
+		writeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "sink_write_errors_total",
+			Help:      "Batch writes to storage that failed by sink.",
+		}, []string{"sink"}),
+		batchSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "batch_size_entries",
+			Help:      "Entries per batch written to storage by sink.",
+			Buckets:   prometheus.ExponentialBuckets(1, 4, 8),
+		}, []string{"sink"}),
+		sources: make(map[source]struct{}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.entries,
+		m.bytes,
+		m.rejected,
+		m.writeDuration,
+		m.writeErrors,
+		m.batchSize,
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest records a completed HTTP request. route is the matched
+// route pattern, not the request path.
+func (m *Metrics) ObserveRequest(route, method string, status int, elapsed time.Duration) {
+	code := strconv.Itoa(status)
+	m.requests.WithLabelValues(route, method, code).Inc()
+	m.requestDuration.WithLabelValues(route, method, code).Observe(elapsed.Seconds())
+}
+
+// ObserveAccepted counts entries accepted into the buffer.
+func (m *Metrics) ObserveAccepted(logs []storage.LogEntry) {
+	for _, entry := range logs {
+		project := strconv.Itoa(entry.ProjectID)
+		name := m.sourceLabel(entry.ProjectID, entry.Source)
+		m.entries.WithLabelValues(project, name).Inc()
+		m.bytes.WithLabelValues(project, name).Add(float64(len(entry.Message)))
+	}
+}
+
+// ObserveRejected counts entries the buffer refused. reason is a short
+// label such as "full".
+func (m *Metrics) ObserveRejected(reason string, entries int) {
+	m.rejected.WithLabelValues(reason).Add(float64(entries))
+}
+
+// WriteObserver returns a sink.Config.OnWrite function recording batch
+// writes of the named sink.
+func (m *Metrics) WriteObserver(sink string) func(entries int, elapsed time.Duration, err error) {
+	writeDuration := m.writeDuration.WithLabelValues(sink)
+	batchSize := m.batchSize.WithLabelValues(sink)
+	writeErrors := m.writeErrors.WithLabelValues(sink)
+	return func(entries int, elapsed time.Duration, err error) {
+		writeDuration.Observe(elapsed.Seconds())
+		batchSize.Observe(float64(entries))
+		if err != nil {
+			writeErrors.Inc()
+		}
+	}
+}
+
+// sourceLabel returns name until MaxSources pairs are known, then
+// OtherSource for new pairs.
+func (m *Metrics) sourceLabel(project int, name string) string {
+	key := source{project: project, name: name}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.sources[key]; ok {
+		return name
+	}
+	if len(m.sources) >= MaxSources {
+		return OtherSource
+	}
+	m.sources[key] = struct{}{}
+	return name
+}
+
+// State reads the service's own counters whenever metrics are collected.
+// Nil functions are not exported.
+type State struct {
+	BufferDepth    func() int
+	BufferCapacity func() int
+	Sinks          func() []sink.Status
+	Dropped        func() int64
+	Duplicates     func() int64
+	Throttled      func() map[string]ratelimit.Counters
+}
+
+// Watch exports state. It must be called at most once.
+func (m *Metrics) Watch(state State) {
+	m.registry.MustRegister(&stateCollector{state: state})
+}
+
+var (
+	bufferDepthDesc = prometheus.NewDesc(namespace+"_buffer_entries",
+		"Entries waiting in the in-memory buffer.", nil, nil)
+	bufferCapacityDesc = prometheus.NewDesc(namespace+"_buffer_capacity_entries",
+		"Capacity of the in-memory buffer.", nil, nil)
+	sinkPendingDesc = prometheus.NewDesc(namespace+"_sink_queue_entries",
+		"Entries waiting in a sink's queue.", []string{"sink"}, nil)
+	sinkFailedDesc = prometheus.NewDesc(namespace+"_sink_failed_batches_total",
+		"Batches a sink failed to write.", []string{"sink"}, nil)
+	sinkDroppedDesc = prometheus.NewDesc(namespace+"_sink_dropped_entries_total",
+		"Entries a sink lost to failed writes or a full queue.", []string{"sink"}, nil)
+	spoolSegmentsDesc = prometheus.NewDesc(namespace+"_spool_segments",
+		"Segments in a sink's disk spool.", []string{"sink"}, nil)
+	spoolBytesDesc = prometheus.NewDesc(namespace+"_spool_bytes",
+		"Bytes in a sink's disk spool.", []string{"sink"}, nil)
+	droppedDesc = prometheus.NewDesc(namespace+"_pipeline_dropped_total",
+		"Entries dropped by pipeline drop and sampling rules.", nil, nil)
+	duplicatesDesc = prometheus.NewDesc(namespace+"_dedup_duplicates_total",
+		"Entries dropped as duplicate event IDs.", nil, nil)
+	throttledRequestsDesc = prometheus.NewDesc(namespace+"_throttled_requests_total",
+		"Requests rejected by rate limits by scope.", []string{"scope"}, nil)
+	throttledEventsDesc = prometheus.NewDesc(namespace+"_throttled_entries_total",
+		"Entries in requests rejected by rate limits by scope.", []string{"scope"}, nil)
+	throttledBytesDesc = prometheus.NewDesc(namespace+"_throttled_bytes_total",
+		"Body bytes of requests rejected by rate limits by scope.", []string{"scope"}, nil)
+)
+
+// stateCollector turns State into metrics at collection time.
+type stateCollector struct {
+	state State
+}
+
+func (c *stateCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		bufferDepthDesc, bufferCapacityDesc,
+		sinkPendingDesc, sinkFailedDesc, sinkDroppedDesc, spoolSegmentsDesc, spoolBytesDesc,
+		droppedDesc, duplicatesDesc,
+		throttledRequestsDesc, throttledEventsDesc, throttledBytesDesc,
+	} {
+		ch <- desc
+	}
+}
+
+func (c *stateCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.state
+	if s.BufferDepth != nil {
+		ch <- prometheus.MustNewConstMetric(bufferDepthDesc, prometheus.GaugeValue, float64(s.BufferDepth()))
+	}
+	if s.BufferCapacity != nil {
+		ch <- prometheus.MustNewConstMetric(bufferCapacityDesc, prometheus.GaugeValue, float64(s.BufferCapacity()))
+	}
+	if s.Sinks != nil {
+		for _, status := range s.Sinks() {
+			ch <- prometheus.MustNewConstMetric(sinkPendingDesc, prometheus.GaugeValue, float64(status.Pending), status.Name)
+			ch <- prometheus.MustNewConstMetric(sinkFailedDesc, prometheus.CounterValue, float64(status.Failed), status.Name)
+			ch <- prometheus.MustNewConstMetric(sinkDroppedDesc, prometheus.CounterValue, float64(status.Dropped), status.Name)
+			if status.Spool != nil {
+				ch <- prometheus.MustNewConstMetric(spoolSegmentsDesc, prometheus.GaugeValue, float64(status.Spool.Segments), status.Name)
+				ch <- prometheus.MustNewConstMetric(spoolBytesDesc, prometheus.GaugeValue, float64(status.Spool.Bytes), status.Name)
+			}
+		}
+	}
+	if s.Dropped != nil {
+		ch <- prometheus.MustNewConstMetric(droppedDesc, prometheus.CounterValue, float64(s.Dropped()))
+	}
+	if s.Duplicates != nil {
+		ch <- prometheus.MustNewConstMetric(duplicatesDesc, prometheus.CounterValue, float64(s.Duplicates()))
+	}
+	if s.Throttled != nil {
+		for scope, counters := range s.Throttled() {
+			ch <- prometheus.MustNewConstMetric(throttledRequestsDesc, prometheus.CounterValue, float64(counters.Requests), scope)
+			ch <- prometheus.MustNewConstMetric(throttledEventsDesc, prometheus.CounterValue, float64(counters.Events), scope)
+			ch <- prometheus.MustNewConstMetric(throttledBytesDesc, prometheus.CounterValue, float64(counters.Bytes), scope)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bizjs/Lograil/ingestion/internal/sink"
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+)
+
+func TestSourceLabelCap(t *testing.T) {
+	m := New()
+
+	for i := 0; i < MaxSources; i++ {
+		if label := m.sourceLabel(1, fmt.Sprint(i)); label != fmt.Sprint(i) {
+			t.Fatalf("Expected source %d to keep its label, got %q", i, label)
+		}
+	}
+	if label := m.sourceLabel(2, "0"); label != OtherSource {
+		t.Errorf("Expected %q past MaxSources, got %q", OtherSource, label)
+	}
+	if label := m.sourceLabel(1, "0"); label != "0" {
+		t.Errorf("Known sources should keep their label, got %q", label)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	m := New()
+	m.ObserveAccepted([]storage.LogEntry{{ProjectID: 3, Source: "api", Message: "hello"}})
+	m.WriteObserver("file")(1, time.Millisecond, errors.New("disk full"))
+	m.ObserveRejected("full", 3)
+	m.Watch(State{
+		BufferDepth: func() int { return 7 },
+		Sinks: func() []sink.Status {
+			return []sink.Status{{Name: "file", Dropped: 2}}
+		},
+	})
+
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := recorder.Body.String()
+	for _, want := range []string{
+		`lograil_ingestion_entries_accepted_total{project="3",source="api"} 1`,
+		`lograil_ingestion_message_bytes_accepted_total{project="3",source="api"} 5`,
+		`lograil_ingestion_sink_write_errors_total{sink="file"} 1`,
+		`lograil_ingestion_batch_size_entries_count{sink="file"} 1`,
+		`lograil_ingestion_entries_rejected_total{reason="full"} 3`,
+		`lograil_ingestion_buffer_entries 7`,
+		`lograil_ingestion_sink_dropped_entries_total{sink="file"} 2`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("Expected %q in metrics output", want)
+		}
+	}
+	if strings.Contains(body, "lograil_ingestion_spool_bytes") {
+		t.Error("Spool size should only be exported for sinks with a spool")
+	}
+}
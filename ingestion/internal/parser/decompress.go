@@ -0,0 +1,21 @@
+package parser
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrTooLarge is returned when a decompressed payload exceeds its limit.
+var ErrTooLarge = errors.New("decompressed payload too large")
+
+// readLimited reads r fully, failing with ErrTooLarge past maxSize bytes.
+func readLimited(r io.Reader, maxSize int) ([]byte, error) {
+	out, err := io.ReadAll(io.LimitReader(r, int64(maxSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > maxSize {
+		return nil, ErrTooLarge
+	}
+	return out, nil
+}
@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+)
+
+// BulkItem is one action of an Elasticsearch _bulk request. Entry is nil
+// when Err is set.
+type BulkItem struct {
+	Action string
+	Index  string
+	ID     string
+	Entry  *storage.LogEntry
+	Err    error
+}
+
+// indexDateSuffix matches rollover suffixes such as "-2024.01.02".
+var indexDateSuffix = regexp.MustCompile(`-\d{4}([.-]\d{2}){0,2}$`)
+
+var (
+	esMessageKeys   = []string{"message", "msg", "@message", "log"}
+	esTimestampKeys = []string{"@timestamp", "timestamp", "time"}
+	esLevelKeys     = []string{"level", "log.level", "severity", "levelname"}
+)
+
+// ParseBulk reads an NDJSON _bulk body of action/document pairs. index and
+// create actions become log entries; other actions are reported as
+// unsupported items. The index name, without a date suffix, becomes the
+// entry source.
+func ParseBulk(r io.Reader, defaultIndex string, now time.Time) ([]BulkItem, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	// lineNo is the 1-based number of the last line scanned
+	lineNo := 0
+	scan := func() bool {
+		lineNo++
+		return scanner.Scan()
+	}
+
+	var items []BulkItem
+	for scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		actionLine := lineNo
+
+		var action map[string]struct {
+			Index string `json:"_index"`
+			ID    string `json:"_id"`
+		}
+		if err := json.Unmarshal(line, &action); err != nil || len(action) != 1 {
+			return nil, fmt.Errorf("malformed action/metadata line %d", actionLine)
+		}
+
+		var item BulkItem
+		for name, meta := range action {
+			item = BulkItem{Action: name, Index: meta.Index, ID: meta.ID}
+		}
+		if item.Index == "" {
+			item.Index = defaultIndex
+		}
+
+		switch item.Action {
+		case "index", "create":
+			if !scan() {
+				return nil, fmt.Errorf("missing document for action line %d", actionLine)
+			}
+			if item.Index == "" {
+				item.Err = errors.New("index is missing")
+			} else {
+				item.Entry, item.Err = bulkDocumentToEntry(scanner.Bytes(), item.Index, now)
+			}
+		case "update":
+			// Skip the partial document that follows an update
+			scan()
+			item.Err = errors.New("update is not supported, logs are append-only")
+		case "delete":
+			item.Err = errors.New("delete is not supported, logs are append-only")
+		default:
+			return nil, fmt.Errorf("unknown bulk action %q", item.Action)
+		}
+
+		items = append(items, item)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read bulk body: %w", err)
+	}
+
+	return items, nil
+}
+
+func bulkDocumentToEntry(doc []byte, index string, now time.Time) (*storage.LogEntry, error) {
+	var fields map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.UseNumber()
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, fmt.Errorf("failed to parse document: %w", err)
+	}
+
+	entry := &storage.LogEntry{
+		Timestamp: now,
+		Level:     "info",
+		Source:    indexDateSuffix.ReplaceAllString(index, ""),
+		Fields:    fields,
+	}
+
+	if message, key := takeString(fields, esMessageKeys); key != "" {
+		entry.Message = message
+	} else {
+		entry.Message = string(bytes.TrimSpace(doc))
+	}
+
+	if value, key := takeString(fields, esTimestampKeys); key != "" {
+		if ts, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			entry.Timestamp = ts
+		} else {
+			fields[key] = value
+		}
+	}
+
+	if level, key := takeString(fields, esLevelKeys); key != "" {
+		entry.Level = level
+	}
+
+	return entry, nil
+}
+
+// takeString removes and returns the first string value found under keys.
+// Dotted keys also match nested objects, as in {"log": {"level": "info"}}.
+func takeString(fields map[string]interface{}, keys []string) (string, string) {
+	for _, key := range keys {
+		if value, ok := fields[key].(string); ok {
+			delete(fields, key)
+			return value, key
+		}
+
+		parent, child, ok := strings.Cut(key, ".")
+		if !ok {
+			continue
+		}
+		if nested, ok := fields[parent].(map[string]interface{}); ok {
+			if value, ok := nested[child].(string); ok {
+				delete(nested, child)
+				if len(nested) == 0 {
+					delete(fields, parent)
+				}
+				return value, key
+			}
+		}
+	}
+	return "", ""
+}
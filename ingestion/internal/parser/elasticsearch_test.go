@@ -0,0 +1,63 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBulk(t *testing.T) {
+	body := `{"index":{"_index":"filebeat-2024.01.02","_id":"1"}}
+{"@timestamp":"2024-01-02T03:04:05Z","message":"connection reset","log":{"level":"error"},"host":{"name":"web-1"}}
+{"create":{}}
+{"msg":"cache warmed"}
+{"delete":{"_index":"filebeat","_id":"2"}}
+`
+
+	items, err := ParseBulk(strings.NewReader(body), "app-logs", time.Now())
+	if err != nil {
+		t.Fatalf("ParseBulk failed: %v", err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("Expected 3 items, got %d", len(items))
+	}
+
+	first := items[0].Entry
+	if items[0].Err != nil || first == nil {
+		t.Fatalf("Expected first item to parse: %v", items[0].Err)
+	}
+	if first.Source != "filebeat" || first.Level != "error" || first.Message != "connection reset" {
+		t.Errorf("Unexpected entry: %+v", first)
+	}
+	if first.Timestamp.Year() != 2024 || first.Fields["log"] != nil {
+		t.Errorf("Expected timestamp and level to be lifted out of fields: %+v", first)
+	}
+
+	if items[1].Index != "app-logs" || items[1].Entry.Message != "cache warmed" {
+		t.Errorf("Expected default index and msg key: %+v", items[1])
+	}
+	if items[2].Action != "delete" || items[2].Err == nil {
+		t.Errorf("Expected delete to be rejected: %+v", items[2])
+	}
+}
+
+func TestParseBulkRejectsMissingDocument(t *testing.T) {
+	if _, err := ParseBulk(strings.NewReader(`{"index":{"_index":"a"}}`), "", time.Now()); err == nil {
+		t.Error("Expected an error for an action without a document")
+	}
+}
+
+func TestParseBulkReportsLineNumbers(t *testing.T) {
+	body := `{"index":{}}
+{"message":"a"}
+{"delete":{"_id":"1"}}
+
+{"update":{"_id":"2"}}
+{"doc":{}}
+not json
+`
+	_, err := ParseBulk(strings.NewReader(body), "app-logs", time.Now())
+	if err == nil || err.Error() != "malformed action/metadata line 7" {
+		t.Errorf("Expected the malformed line to be 7, got %v", err)
+	}
+}
@@ -0,0 +1,270 @@
+package parser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+)
+
+// ErrInvalidForward is returned for messages that do not follow the
+// Fluentd Forward protocol.
+var ErrInvalidForward = errors.New("invalid forward message")
+
+var (
+	forwardMessageKeys = []string{"log", "message", "msg"}
+	forwardLevelKeys   = []string{"level", "severity", "log.level"}
+)
+
+// EventTime is the Forward protocol timestamp extension (type 0): seconds
+// and nanoseconds as two big-endian uint32 values.
+type EventTime struct {
+	time.Time
+}
+
+func (t *EventTime) MarshalMsgpack() ([]byte, error) {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint32(b, uint32(t.Unix()))
+	binary.BigEndian.PutUint32(b[4:], uint32(t.Nanosecond()))
+	return b, nil
+}
+
+func (t *EventTime) UnmarshalMsgpack(b []byte) error {
+	if len(b) != 8 {
+		return fmt.Errorf("%w: EventTime has %d bytes", ErrInvalidForward, len(b))
+	}
+	sec := binary.BigEndian.Uint32(b)
+	nsec := binary.BigEndian.Uint32(b[4:])
+	t.Time = time.Unix(int64(sec), int64(nsec))
+	return nil
+}
+
+func init() {
+	msgpack.RegisterExt(0, (*EventTime)(nil))
+}
+
+// ForwardMessage is one decoded Forward protocol message. Chunk is set when
+// the client expects an ack for it.
+type ForwardMessage struct {
+	Tag     string
+	Entries []storage.LogEntry
+	Chunk   string
+}
+
+// DecodeForward reads the next message from dec in Message, Forward or
+// PackedForward mode, including gzip CompressedPackedForward. maxSize
+// bounds packed and decompressed payloads. io.EOF is returned unchanged
+// at the end of the stream.
+func DecodeForward(dec *msgpack.Decoder, maxSize int, now time.Time) (*ForwardMessage, error) {
+	dec.UseLooseInterfaceDecoding(true)
+
+	n, err := dec.DecodeArrayLen()
+	if err != nil {
+		return nil, err
+	}
+	if n < 2 {
+		return nil, fmt.Errorf("%w: array of %d elements", ErrInvalidForward, n)
+	}
+
+	tag, err := dec.DecodeString()
+	if err != nil {
+		return nil, fmt.Errorf("%w: tag: %v", ErrInvalidForward, err)
+	}
+
+	code, err := dec.PeekCode()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		entries []interface{}
+		packed  []byte
+		single  []interface{}
+	)
+	remaining := n - 2
+	switch {
+	case msgpcode.IsFixedArray(code) || code == msgpcode.Array16 || code == msgpcode.Array32:
+		value, err := dec.DecodeInterface()
+		if err != nil {
+			return nil, fmt.Errorf("%w: entries: %v", ErrInvalidForward, err)
+		}
+		entries, _ = value.([]interface{})
+	case msgpcode.IsString(code) || msgpcode.IsBin(code):
+		packed, err = dec.DecodeBytes()
+		if err != nil {
+			return nil, fmt.Errorf("%w: packed entries: %v", ErrInvalidForward, err)
+		}
+		if len(packed) > maxSize {
+			return nil, fmt.Errorf("%w: packed entries exceed %d bytes", ErrInvalidForward, maxSize)
+		}
+	default:
+		if remaining < 1 {
+			return nil, fmt.Errorf("%w: message without record", ErrInvalidForward)
+		}
+		timestamp, err := dec.DecodeInterface()
+		if err != nil {
+			return nil, fmt.Errorf("%w: time: %v", ErrInvalidForward, err)
+		}
+		record, err := dec.DecodeInterface()
+		if err != nil {
+			return nil, fmt.Errorf("%w: record: %v", ErrInvalidForward, err)
+		}
+		single = []interface{}{timestamp, record}
+		remaining--
+	}
+
+	var option map[string]interface{}
+	if remaining > 0 {
+		value, err := dec.DecodeInterface()
+		if err != nil {
+			return nil, fmt.Errorf("%w: option: %v", ErrInvalidForward, err)
+		}
+		option, _ = value.(map[string]interface{})
+		remaining--
+	}
+	for ; remaining > 0; remaining-- {
+		if err := dec.Skip(); err != nil {
+			return nil, err
+		}
+	}
+
+	msg := &ForwardMessage{Tag: tag}
+	msg.Chunk, _ = option["chunk"].(string)
+
+	switch {
+	case single != nil:
+		entry, err := forwardEntry(tag, single, now)
+		if err != nil {
+			return nil, err
+		}
+		msg.Entries = []storage.LogEntry{entry}
+	case packed != nil:
+		if compressed, _ := option["compressed"].(string); compressed != "" {
+			if compressed != "gzip" {
+				return nil, fmt.Errorf("%w: unsupported compression %q", ErrInvalidForward, compressed)
+			}
+			if packed, err = gunzip(packed, maxSize); err != nil {
+				return nil, err
+			}
+		}
+		if msg.Entries, err = decodePackedEntries(tag, packed, now); err != nil {
+			return nil, err
+		}
+	default:
+		for _, value := range entries {
+			pair, _ := value.([]interface{})
+			entry, err := forwardEntry(tag, pair, now)
+			if err != nil {
+				return nil, err
+			}
+			msg.Entries = append(msg.Entries, entry)
+		}
+	}
+
+	return msg, nil
+}
+
+// decodePackedEntries decodes a stream of concatenated [time, record] arrays.
+func decodePackedEntries(tag string, packed []byte, now time.Time) ([]storage.LogEntry, error) {
+	dec := msgpack.NewDecoder(bytes.NewReader(packed))
+	dec.UseLooseInterfaceDecoding(true)
+
+	var logs []storage.LogEntry
+	for {
+		value, err := dec.DecodeInterface()
+		if err == io.EOF {
+			return logs, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: packed entry: %v", ErrInvalidForward, err)
+		}
+
+		pair, _ := value.([]interface{})
+		entry, err := forwardEntry(tag, pair, now)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, entry)
+	}
+}
+
+func gunzip(data []byte, maxSize int) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: gzip: %v", ErrInvalidForward, err)
+	}
+	defer reader.Close()
+
+	out, err := readLimited(reader, maxSize)
+	if err != nil {
+		return nil, fmt.Errorf("%w: gzip: %w", ErrInvalidForward, err)
+	}
+	return out, nil
+}
+
+// forwardEntry converts a [time, record] pair. The tag becomes the source;
+// the log/message/msg and level keys are lifted out of the record.
+func forwardEntry(tag string, pair []interface{}, now time.Time) (storage.LogEntry, error) {
+	if len(pair) < 2 {
+		return storage.LogEntry{}, fmt.Errorf("%w: entry is not a [time, record] pair", ErrInvalidForward)
+	}
+
+	fields, ok := pair[1].(map[string]interface{})
+	if !ok {
+		return storage.LogEntry{}, fmt.Errorf("%w: record is %T, not a map", ErrInvalidForward, pair[1])
+	}
+
+	entry := storage.LogEntry{
+		Timestamp: forwardTime(pair[0], now),
+		Level:     "info",
+		Source:    tag,
+		Fields:    fields,
+	}
+
+	if message, key := takeString(fields, forwardMessageKeys); key != "" {
+		entry.Message = strings.TrimRight(message, "\r\n")
+	} else if raw, err := json.Marshal(fields); err == nil {
+		entry.Message = string(raw)
+	}
+
+	if level, key := takeString(fields, forwardLevelKeys); key != "" {
+		entry.Level = level
+	}
+
+	if len(fields) == 0 {
+		entry.Fields = nil
+	}
+
+	return entry, nil
+}
+
+func forwardTime(value interface{}, now time.Time) time.Time {
+	switch t := value.(type) {
+	case *EventTime:
+		return t.Time
+	case int64:
+		if t > 0 {
+			return time.Unix(t, 0)
+		}
+	case uint64:
+		if t > 0 {
+			return time.Unix(int64(t), 0)
+		}
+	case float64:
+		if t > 0 {
+			sec := int64(t)
+			return time.Unix(sec, int64((t-float64(sec))*1e9))
+		}
+	}
+	return now
+}
@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func encodeMsgpack(t *testing.T, values ...interface{}) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	for _, v := range values {
+		if err := enc.Encode(v); err != nil {
+			t.Fatalf("Encode failed: %v", err)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeForwardModes(t *testing.T) {
+	now := time.Unix(1800000000, 0)
+	eventTime := &EventTime{time.Unix(1700000000, 42)}
+	record := map[string]interface{}{"log": "started\n", "level": "warn", "pod": "api-1"}
+
+	entry := []interface{}{eventTime, record}
+	packed := encodeMsgpack(t, entry, []interface{}{1700000001, map[string]interface{}{"message": "second"}})
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write(packed)
+	zw.Close()
+
+	stream := encodeMsgpack(t,
+		[]interface{}{"app.message", eventTime, record},
+		[]interface{}{"app.forward", []interface{}{entry, entry}, map[string]interface{}{"chunk": "c1"}},
+		[]interface{}{"app.packed", packed},
+		[]interface{}{"app.gzip", compressed.Bytes(), map[string]interface{}{"compressed": "gzip", "chunk": "c2"}},
+	)
+
+	dec := msgpack.NewDecoder(bytes.NewReader(stream))
+	expected := []struct {
+		tag   string
+		count int
+		chunk string
+	}{
+		{"app.message", 1, ""},
+		{"app.forward", 2, "c1"},
+		{"app.packed", 2, ""},
+		{"app.gzip", 2, "c2"},
+	}
+
+	for _, want := range expected {
+		msg, err := DecodeForward(dec, 1<<20, now)
+		if err != nil {
+			t.Fatalf("DecodeForward(%s) failed: %v", want.tag, err)
+		}
+		if msg.Tag != want.tag || len(msg.Entries) != want.count || msg.Chunk != want.chunk {
+			t.Fatalf("Unexpected message for %s: %+v", want.tag, msg)
+		}
+
+		first := msg.Entries[0]
+		if first.Source != want.tag || first.Message != "started" || first.Level != "warn" {
+			t.Errorf("Unexpected entry for %s: %+v", want.tag, first)
+		}
+		if !first.Timestamp.Equal(eventTime.Time) || first.Fields["pod"] != "api-1" {
+			t.Errorf("Unexpected timestamp or fields for %s: %+v", want.tag, first)
+		}
+	}
+
+	if _, err := DecodeForward(dec, 1<<20, now); err != io.EOF {
+		t.Errorf("Expected io.EOF at end of stream, got %v", err)
+	}
+}
+
+func TestDecodeForwardRejectsOversizedChunk(t *testing.T) {
+	payload := encodeMsgpack(t, []interface{}{"app", make([]byte, 64)})
+
+	_, err := DecodeForward(msgpack.NewDecoder(bytes.NewReader(payload)), 16, time.Now())
+	if err == nil {
+		t.Fatal("Expected an error for an oversized chunk")
+	}
+}
@@ -0,0 +1,152 @@
+package parser
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+)
+
+// ErrInvalidGELF is returned for payloads that are not GELF messages.
+var ErrInvalidGELF = errors.New("invalid GELF message")
+
+// ParseGELF parses a single, already reassembled GELF payload. gzip and
+// zlib compression are detected from the payload's magic bytes; maxSize
+// bounds the decompressed message.
+func ParseGELF(payload []byte, maxSize int, now time.Time) (storage.LogEntry, error) {
+	data, err := inflateGELF(payload, maxSize)
+	if err != nil {
+		return storage.LogEntry{}, err
+	}
+
+	var message map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&message); err != nil {
+		return storage.LogEntry{}, fmt.Errorf("%w: %v", ErrInvalidGELF, err)
+	}
+
+	shortMessage, ok := message["short_message"].(string)
+	if !ok {
+		return storage.LogEntry{}, fmt.Errorf("%w: missing short_message", ErrInvalidGELF)
+	}
+
+	entry := storage.LogEntry{
+		Timestamp: now,
+		Level:     "info",
+		Message:   shortMessage,
+		Fields:    make(map[string]interface{}),
+	}
+
+	for key, value := range message {
+		switch key {
+		case "short_message", "version":
+		case "full_message":
+			entry.Fields["full_message"] = value
+		case "host":
+			if host, ok := value.(string); ok {
+				entry.Source = host
+			}
+		case "timestamp":
+			if ts, ok := gelfTimestamp(value); ok {
+				entry.Timestamp = ts
+			}
+		case "level":
+			if level, ok := gelfLevel(value); ok {
+				entry.Level = level
+			}
+		case "_id":
+			// Reserved by the GELF specification
+		default:
+			// Additional fields are prefixed with "_"; the deprecated
+			// facility, file and line fields are kept as they are
+			entry.Fields[strings.TrimPrefix(key, "_")] = value
+		}
+	}
+
+	if entry.Source == "" {
+		entry.Source = "gelf"
+	}
+	if len(entry.Fields) == 0 {
+		entry.Fields = nil
+	}
+
+	return entry, nil
+}
+
+func inflateGELF(payload []byte, maxSize int) ([]byte, error) {
+	var (
+		reader io.ReadCloser
+		err    error
+	)
+	switch {
+	case len(payload) >= 2 && payload[0] == 0x1f && payload[1] == 0x8b:
+		reader, err = gzip.NewReader(bytes.NewReader(payload))
+	case len(payload) >= 2 && payload[0] == 0x78 && (uint16(payload[0])<<8|uint16(payload[1]))%31 == 0:
+		reader, err = zlib.NewReader(bytes.NewReader(payload))
+	default:
+		if len(payload) > maxSize {
+			return nil, ErrTooLarge
+		}
+		return payload, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidGELF, err)
+	}
+	defer reader.Close()
+
+	data, err := readLimited(reader, maxSize)
+	if err != nil && !errors.Is(err, ErrTooLarge) {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidGELF, err)
+	}
+	return data, err
+}
+
+// gelfTimestamp converts seconds since the epoch with optional decimal
+// milliseconds.
+func gelfTimestamp(value interface{}) (time.Time, bool) {
+	number, ok := value.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	seconds, err := strconv.ParseFloat(number.String(), 64)
+	if err != nil || seconds <= 0 {
+		return time.Time{}, false
+	}
+	sec := int64(seconds)
+	return time.Unix(sec, int64((seconds-float64(sec))*1e9)).Round(time.Microsecond), true
+}
+
+// gelfLevel maps the syslog severity carried in the level field to a name.
+func gelfLevel(value interface{}) (string, bool) {
+	var severity int64
+	switch v := value.(type) {
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil {
+			return "", false
+		}
+		severity = n
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return v, v != ""
+		}
+		severity = n
+	default:
+		return "", false
+	}
+
+	if severity < 0 || severity >= int64(len(syslogSeverities)) {
+		return "", false
+	}
+	return syslogSeverities[severity], true
+}
@@ -0,0 +1,54 @@
+package parser
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseGELF(t *testing.T) {
+	payload := `{"version":"1.1","host":"web-1","short_message":"disk full","full_message":"disk full\nstack",
+		"timestamp":1700000000.25,"level":3,"_container_name":"api","_id":"ignored"}`
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write([]byte(payload))
+	zw.Close()
+
+	for name, body := range map[string][]byte{"plain": []byte(payload), "zlib": compressed.Bytes()} {
+		entry, err := ParseGELF(body, 1<<20, time.Now())
+		if err != nil {
+			t.Fatalf("ParseGELF(%s) failed: %v", name, err)
+		}
+
+		if entry.Source != "web-1" || entry.Message != "disk full" || entry.Level != "error" {
+			t.Errorf("Unexpected entry for %s: %+v", name, entry)
+		}
+		if entry.Timestamp.UnixMilli() != 1700000000250 {
+			t.Errorf("Unexpected timestamp for %s: %v", name, entry.Timestamp)
+		}
+		if entry.Fields["container_name"] != "api" || entry.Fields["full_message"] != "disk full\nstack" {
+			t.Errorf("Unexpected fields for %s: %v", name, entry.Fields)
+		}
+		if _, ok := entry.Fields["id"]; ok {
+			t.Errorf("Reserved _id field should be dropped for %s", name)
+		}
+	}
+}
+
+func TestParseGELFLimits(t *testing.T) {
+	if _, err := ParseGELF([]byte(`{"host":"a"}`), 1024, time.Now()); !errors.Is(err, ErrInvalidGELF) {
+		t.Errorf("Expected ErrInvalidGELF without short_message, got %v", err)
+	}
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(bytes.Repeat([]byte("a"), 4096))
+	zw.Close()
+
+	if _, err := ParseGELF(compressed.Bytes(), 1024, time.Now()); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected ErrTooLarge for an oversized payload, got %v", err)
+	}
+}
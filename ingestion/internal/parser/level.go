@@ -0,0 +1,113 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Canonical levels, from least to most severe.
+const (
+	LevelTrace  = "trace"
+	LevelDebug  = "debug"
+	LevelInfo   = "info"
+	LevelNotice = "notice"
+	LevelWarn   = "warn"
+	LevelError  = "error"
+	LevelFatal  = "fatal"
+)
+
+// levelAliases maps lower-case spellings to canonical levels.
+var levelAliases = map[string]string{
+	"trace": LevelTrace, "trc": LevelTrace, "t": LevelTrace, "verbose": LevelTrace, "finest": LevelTrace, "finer": LevelTrace,
+	"debug": LevelDebug, "dbg": LevelDebug, "d": LevelDebug, "fine": LevelDebug,
+	"debug1": LevelDebug, "debug2": LevelDebug, "debug3": LevelDebug, "debug4": LevelDebug, "debug5": LevelDebug,
+	"info": LevelInfo, "inf": LevelInfo, "i": LevelInfo, "information": LevelInfo, "informational": LevelInfo, "log": LevelInfo,
+	"notice": LevelNotice, "ntc": LevelNotice, "n": LevelNotice,
+	"warn": LevelWarn, "warning": LevelWarn, "wrn": LevelWarn, "w": LevelWarn,
+	"error": LevelError, "err": LevelError, "e": LevelError, "severe": LevelError,
+	"fatal": LevelFatal, "ftl": LevelFatal, "f": LevelFatal, "panic": LevelFatal,
+	"critical": LevelFatal, "crit": LevelFatal, "c": LevelFatal,
+	"alert": LevelFatal, "emergency": LevelFatal, "emerg": LevelFatal,
+}
+
+// LevelScheme names a numeric level scheme. The schemes overlap: 3 is an
+// error in syslog but debug in OpenTelemetry, and 20 is debug in pino but
+// an error in OpenTelemetry.
+type LevelScheme string
+
+const (
+	// SchemeSyslog reads syslog severities, 0-7.
+	SchemeSyslog LevelScheme = "syslog"
+	// SchemePino reads pino/bunyan levels, 10-60 in steps of 10.
+	SchemePino LevelScheme = "pino"
+	// SchemeOTel reads OpenTelemetry severity numbers, 1-24.
+	SchemeOTel LevelScheme = "otel"
+)
+
+// levelSchemes converts a number of each scheme to a canonical level.
+var levelSchemes = []struct {
+	scheme LevelScheme
+	level  func(n int) (string, bool)
+}{
+	{SchemeSyslog, func(n int) (string, bool) {
+		if n < 0 || n >= len(syslogSeverities) {
+			return "", false
+		}
+		return levelAliases[syslogSeverities[n]], true
+	}},
+	{SchemePino, func(n int) (string, bool) {
+		if n < 10 || n > 60 || n%10 != 0 {
+			return "", false
+		}
+		return []string{LevelTrace, LevelDebug, LevelInfo, LevelWarn, LevelError, LevelFatal}[n/10-1], true
+	}},
+	{SchemeOTel, func(n int) (string, bool) {
+		if n < 1 || n > 24 {
+			return "", false
+		}
+		return []string{LevelTrace, LevelDebug, LevelInfo, LevelWarn, LevelError, LevelFatal}[(n-1)/4], true
+	}},
+}
+
+// ParseLevelScheme validates a numeric level scheme name.
+func ParseLevelScheme(name string) (LevelScheme, error) {
+	for _, s := range levelSchemes {
+		if string(s.scheme) == name {
+			return s.scheme, nil
+		}
+	}
+	return "", fmt.Errorf("unknown level scheme %q", name)
+}
+
+// NormalizeLevel maps known level spellings and numeric levels to a
+// canonical level, reporting whether the level was recognised. Numbers are
+// read in scheme; without one, only numbers defined by a single scheme are
+// recognised, such as 30 for pino or 17 for OpenTelemetry, while 1-7, 10
+// and 20 are left as they are.
+func NormalizeLevel(level string, scheme LevelScheme) (string, bool) {
+	normalized := strings.ToLower(strings.TrimSpace(level))
+	if canonical, ok := levelAliases[normalized]; ok {
+		return canonical, true
+	}
+
+	n, err := strconv.Atoi(normalized)
+	if err != nil {
+		return level, false
+	}
+
+	canonical, matches := "", 0
+	for _, s := range levelSchemes {
+		if scheme != "" && s.scheme != scheme {
+			continue
+		}
+		if l, ok := s.level(n); ok {
+			canonical = l
+			matches++
+		}
+	}
+	if matches != 1 {
+		return level, false
+	}
+	return canonical, true
+}
@@ -0,0 +1,42 @@
+package parser
+
+import "testing"
+
+func TestNormalizeLevel(t *testing.T) {
+	tests := []struct {
+		level  string
+		scheme LevelScheme
+		want   string
+		ok     bool
+	}{
+		{"WARN", "", "warn", true},
+		{"Warning", "", "warn", true},
+		{" e ", "", "error", true},
+		{"CRIT", "", "fatal", true},
+		{"DEBUG3", "", "debug", true},
+		{"LOG", "", "info", true},
+		{"0", "", "fatal", true},  // syslog emerg
+		{"30", "", "info", true},  // pino info
+		{"60", "", "fatal", true}, // pino fatal
+		{"9", "", "info", true},   // OTel INFO
+		{"17", "", "error", true}, // OTel ERROR
+		{"24", "", "fatal", true}, // OTel FATAL4
+		{"3", "", "3", false},     // syslog err or OTel DEBUG3
+		{"20", "", "20", false},   // pino debug or OTel ERROR4
+		{"3", SchemeSyslog, "error", true},
+		{"7", SchemeSyslog, "debug", true},
+		{"1", SchemeOTel, "trace", true},
+		{"20", SchemeOTel, "error", true},
+		{"10", SchemePino, "trace", true},
+		{"20", SchemePino, "debug", true},
+		{"17", SchemePino, "17", false}, // not a pino level
+		{"99", "", "99", false},         // out of every range
+		{"loud", "", "loud", false},
+	}
+	for _, tt := range tests {
+		got, ok := NormalizeLevel(tt.level, tt.scheme)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("NormalizeLevel(%q, %q) = %q, %v, want %q, %v", tt.level, tt.scheme, got, ok, tt.want, tt.ok)
+		}
+	}
+}
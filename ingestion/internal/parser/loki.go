@@ -0,0 +1,336 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// lokiSourceLabels are checked in order to pick the entry source.
+var lokiSourceLabels = []string{"service_name", "app", "job", "container", "host"}
+
+// lokiLevelLabels are checked in order to pick the entry level.
+var lokiLevelLabels = []string{"level", "detected_level", "severity"}
+
+type lokiEntry struct {
+	timestamp time.Time
+	line      string
+	metadata  map[string]string
+}
+
+// DecodeLokiJSON decodes a Loki push request in JSON encoding:
+// {"streams":[{"stream":{...},"values":[["<ns>","<line>",{...}]]}]}.
+func DecodeLokiJSON(body []byte) ([]storage.LogEntry, error) {
+	var req struct {
+		Streams []struct {
+			Stream map[string]string   `json:"stream"`
+			Values [][]json.RawMessage `json:"values"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, fmt.Errorf("invalid Loki JSON payload: %w", err)
+	}
+
+	var entries []storage.LogEntry
+	for _, stream := range req.Streams {
+		lokiEntries := make([]lokiEntry, 0, len(stream.Values))
+		for _, value := range stream.Values {
+			if len(value) < 2 {
+				return nil, errors.New("invalid Loki JSON payload: value must be [timestamp, line]")
+			}
+
+			var ts, line string
+			if err := json.Unmarshal(value[0], &ts); err != nil {
+				return nil, fmt.Errorf("invalid Loki JSON payload: timestamp must be a string: %w", err)
+			}
+			if err := json.Unmarshal(value[1], &line); err != nil {
+				return nil, fmt.Errorf("invalid Loki JSON payload: line must be a string: %w", err)
+			}
+			nanos, err := strconv.ParseInt(ts, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid Loki JSON payload: bad timestamp %q", ts)
+			}
+
+			entry := lokiEntry{timestamp: time.Unix(0, nanos).UTC(), line: line}
+			if len(value) > 2 {
+				if err := json.Unmarshal(value[2], &entry.metadata); err != nil {
+					return nil, fmt.Errorf("invalid Loki JSON payload: bad structured metadata: %w", err)
+				}
+			}
+			lokiEntries = append(lokiEntries, entry)
+		}
+
+		entries = append(entries, convertLokiStream(stream.Stream, lokiEntries)...)
+	}
+
+	return entries, nil
+}
+
+// DecodeLokiProtobuf decodes a snappy-compressed logproto.PushRequest as
+// sent by Promtail and Grafana Alloy. Payloads decoding to more than
+// maxSize bytes fail with ErrTooLarge before anything is allocated.
+func DecodeLokiProtobuf(body []byte, maxSize int) ([]storage.LogEntry, error) {
+	decodedLen, err := snappy.DecodedLen(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Loki payload: snappy: %w", err)
+	}
+	if decodedLen > maxSize {
+		return nil, ErrTooLarge
+	}
+
+	decoded, err := snappy.Decode(nil, body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Loki payload: snappy: %w", err)
+	}
+
+	var entries []storage.LogEntry
+	err = walkProto(decoded, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		if num != 1 || typ != protowire.BytesType {
+			return nil
+		}
+
+		labelString, streamEntries, err := decodeLokiStream(value)
+		if err != nil {
+			return err
+		}
+		labels, err := ParsePromLabels(labelString)
+		if err != nil {
+			return err
+		}
+
+		entries = append(entries, convertLokiStream(labels, streamEntries)...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid Loki protobuf payload: %w", err)
+	}
+
+	return entries, nil
+}
+
+// decodeLokiStream decodes a StreamAdapter: labels = 1, entries = 2.
+func decodeLokiStream(data []byte) (string, []lokiEntry, error) {
+	var labels string
+	var entries []lokiEntry
+
+	err := walkProto(data, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+
+		switch num {
+		case 1:
+			labels = string(value)
+		case 2:
+			entry, err := decodeLokiEntry(value)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+
+	return labels, entries, err
+}
+
+// decodeLokiEntry decodes an EntryAdapter: timestamp = 1, line = 2,
+// structuredMetadata = 3.
+func decodeLokiEntry(data []byte) (lokiEntry, error) {
+	var entry lokiEntry
+
+	err := walkProto(data, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+
+		switch num {
+		case 1:
+			ts, err := decodeProtoTimestamp(value)
+			if err != nil {
+				return err
+			}
+			entry.timestamp = ts
+		case 2:
+			entry.line = string(value)
+		case 3:
+			var name, val string
+			err := walkProto(value, func(num protowire.Number, typ protowire.Type, value []byte) error {
+				switch {
+				case num == 1 && typ == protowire.BytesType:
+					name = string(value)
+				case num == 2 && typ == protowire.BytesType:
+					val = string(value)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if entry.metadata == nil {
+				entry.metadata = make(map[string]string)
+			}
+			entry.metadata[name] = val
+		}
+		return nil
+	})
+
+	return entry, err
+}
+
+// decodeProtoTimestamp decodes a google.protobuf.Timestamp.
+func decodeProtoTimestamp(data []byte) (time.Time, error) {
+	var seconds, nanos int64
+
+	err := walkProto(data, func(num protowire.Number, typ protowire.Type, value []byte) error {
+		if typ != protowire.VarintType {
+			return nil
+		}
+		v, _ := protowire.ConsumeVarint(value)
+		switch num {
+		case 1:
+			seconds = int64(v)
+		case 2:
+			nanos = int64(int32(v))
+		}
+		return nil
+	})
+
+	return time.Unix(seconds, nanos).UTC(), err
+}
+
+// walkProto calls fn for every field of a protobuf message. For varint
+// fields, value holds the raw varint bytes.
+func walkProto(data []byte, fn func(protowire.Number, protowire.Type, []byte) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		var value []byte
+		switch typ {
+		case protowire.BytesType:
+			v, m := protowire.ConsumeBytes(data)
+			if m < 0 {
+				return protowire.ParseError(m)
+			}
+			value, n = v, m
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			value = data[:n]
+		}
+
+		if err := fn(num, typ, value); err != nil {
+			return err
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+func convertLokiStream(labels map[string]string, lokiEntries []lokiEntry) []storage.LogEntry {
+	source := firstLabel(labels, lokiSourceLabels)
+	if source == "" {
+		source = "loki"
+	}
+	streamLevel := firstLabel(labels, lokiLevelLabels)
+
+	entries := make([]storage.LogEntry, 0, len(lokiEntries))
+	for _, e := range lokiEntries {
+		fields := make(map[string]interface{}, len(labels)+len(e.metadata))
+		for k, v := range labels {
+			fields[k] = v
+		}
+		for k, v := range e.metadata {
+			fields[k] = v
+		}
+
+		level := firstLabel(e.metadata, lokiLevelLabels)
+		if level == "" {
+			level = streamLevel
+		}
+		if level == "" {
+			level = "info"
+		}
+
+		entries = append(entries, storage.LogEntry{
+			Timestamp: e.timestamp,
+			Level:     level,
+			Message:   e.line,
+			Source:    source,
+			Fields:    fields,
+		})
+	}
+
+	return entries
+}
+
+func firstLabel(labels map[string]string, names []string) string {
+	for _, name := range names {
+		if value := labels[name]; value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// ParsePromLabels parses a Prometheus label set such as
+// `{job="varlogs", host="web-1"}`.
+func ParsePromLabels(s string) (map[string]string, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "{") || !strings.HasSuffix(s, "}") {
+		return nil, fmt.Errorf("invalid label set %q", s)
+	}
+	s = s[1 : len(s)-1]
+
+	labels := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " ,")
+		if s == "" {
+			return labels, nil
+		}
+
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 || eq+1 >= len(s) || s[eq+1] != '"' {
+			return nil, fmt.Errorf("invalid label set near %q", s)
+		}
+		name := strings.TrimSpace(s[:eq])
+		s = s[eq+2:]
+
+		var value bytes.Buffer
+		i := 0
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+				switch s[i] {
+				case 'n':
+					value.WriteByte('\n')
+					continue
+				case 't':
+					value.WriteByte('\t')
+					continue
+				}
+			}
+			value.WriteByte(s[i])
+		}
+		if i >= len(s) {
+			return nil, fmt.Errorf("unterminated label value for %q", name)
+		}
+
+		labels[name] = value.String()
+		s = s[i+1:]
+	}
+}
@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestDecodeLokiJSON(t *testing.T) {
+	body := `{"streams":[{"stream":{"job":"varlogs","level":"warn"},
+		"values":[["1700000000000000000","disk almost full",{"trace_id":"abc"}]]}]}`
+
+	entries, err := DecodeLokiJSON([]byte(body))
+	if err != nil {
+		t.Fatalf("DecodeLokiJSON failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(entries))
+	}
+
+	entry := entries[0]
+	if entry.Source != "varlogs" || entry.Level != "warn" || entry.Message != "disk almost full" {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+	if entry.Timestamp.UnixNano() != 1700000000000000000 || entry.Fields["trace_id"] != "abc" {
+		t.Errorf("Unexpected timestamp or fields: %+v", entry)
+	}
+}
+
+func TestDecodeLokiProtobuf(t *testing.T) {
+	var timestamp []byte
+	timestamp = protowire.AppendTag(timestamp, 1, protowire.VarintType)
+	timestamp = protowire.AppendVarint(timestamp, 1700000000)
+	timestamp = protowire.AppendTag(timestamp, 2, protowire.VarintType)
+	timestamp = protowire.AppendVarint(timestamp, 5)
+
+	var entry []byte
+	entry = protowire.AppendTag(entry, 1, protowire.BytesType)
+	entry = protowire.AppendBytes(entry, timestamp)
+	entry = protowire.AppendTag(entry, 2, protowire.BytesType)
+	entry = protowire.AppendString(entry, "GET /health 200")
+
+	var stream []byte
+	stream = protowire.AppendTag(stream, 1, protowire.BytesType)
+	stream = protowire.AppendString(stream, `{app="nginx", host="web-1", path="C:\\logs"}`)
+	stream = protowire.AppendTag(stream, 2, protowire.BytesType)
+	stream = protowire.AppendBytes(stream, entry)
+
+	var push []byte
+	push = protowire.AppendTag(push, 1, protowire.BytesType)
+	push = protowire.AppendBytes(push, stream)
+
+	entries, err := DecodeLokiProtobuf(snappy.Encode(nil, push), 1<<20)
+	if err != nil {
+		t.Fatalf("DecodeLokiProtobuf failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(entries))
+	}
+
+	got := entries[0]
+	if got.Source != "nginx" || got.Level != "info" || got.Message != "GET /health 200" {
+		t.Errorf("Unexpected entry: %+v", got)
+	}
+	if got.Timestamp.Unix() != 1700000000 || got.Timestamp.Nanosecond() != 5 {
+		t.Errorf("Unexpected timestamp: %v", got.Timestamp)
+	}
+	if got.Fields["host"] != "web-1" || got.Fields["path"] != `C:\logs` {
+		t.Errorf("Unexpected labels: %v", got.Fields)
+	}
+}
+
+func TestDecodeLokiProtobufTooLarge(t *testing.T) {
+	// A block header claiming 1 GiB, followed by a few bytes
+	body := append(binary.AppendUvarint(nil, 1<<30), 0, 0, 0, 0)
+	if _, err := DecodeLokiProtobuf(body, 1<<20); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected ErrTooLarge, got %v", err)
+	}
+}
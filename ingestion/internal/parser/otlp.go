@@ -0,0 +1,279 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+	commonv1 "go.opentelemetry.io/proto/otlp/common/v1"
+	logsv1 "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// DecodeOTLPProtobuf decodes a protobuf ExportLogsServiceRequest. Its wire
+// format is identical to LogsData, which avoids depending on the gRPC
+// service definitions.
+func DecodeOTLPProtobuf(body []byte, now time.Time) ([]storage.LogEntry, error) {
+	var data logsv1.LogsData
+	if err := proto.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("invalid OTLP protobuf payload: %w", err)
+	}
+	return ConvertOTLP(&data, now), nil
+}
+
+// EncodeOTLPResponseProtobuf encodes an ExportLogsServiceResponse, with a
+// partial_success reporting rejected records if there are any. It is
+// written by hand for the same reason as in DecodeOTLPProtobuf.
+func EncodeOTLPResponseProtobuf(rejected int, message string) []byte {
+	if rejected == 0 {
+		return nil
+	}
+	var partial []byte
+	partial = protowire.AppendTag(partial, 1, protowire.VarintType)
+	partial = protowire.AppendVarint(partial, uint64(rejected))
+	partial = protowire.AppendTag(partial, 2, protowire.BytesType)
+	partial = protowire.AppendString(partial, message)
+
+	var response []byte
+	response = protowire.AppendTag(response, 1, protowire.BytesType)
+	return protowire.AppendBytes(response, partial)
+}
+
+// EncodeOTLPResponseJSON encodes an ExportLogsServiceResponse as OTLP/JSON,
+// where int64 values are strings.
+func EncodeOTLPResponseJSON(rejected int, message string) []byte {
+	if rejected == 0 {
+		return []byte("{}")
+	}
+	response, _ := json.Marshal(map[string]interface{}{
+		"partialSuccess": map[string]string{
+			"rejectedLogRecords": strconv.Itoa(rejected),
+			"errorMessage":       message,
+		},
+	})
+	return response
+}
+
+// DecodeOTLPJSON decodes a JSON ExportLogsServiceRequest. OTLP/JSON encodes
+// trace and span IDs as hex rather than the base64 protojson expects, so
+// they are rewritten before decoding.
+func DecodeOTLPJSON(body []byte, now time.Time) ([]storage.LogEntry, error) {
+	// UseNumber keeps 64-bit timestamps exact through the round trip
+	var raw map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("invalid OTLP JSON payload: %w", err)
+	}
+	if err := hexIDsToBase64(raw); err != nil {
+		return nil, err
+	}
+
+	normalized, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OTLP JSON payload: %w", err)
+	}
+
+	var data logsv1.LogsData
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(normalized, &data); err != nil {
+		return nil, fmt.Errorf("invalid OTLP JSON payload: %w", err)
+	}
+	return ConvertOTLP(&data, now), nil
+}
+
+// ConvertOTLP maps OTLP log records onto log entries. Resource, scope and
+// record attributes become fields, with record attributes taking precedence;
+// service.name becomes the source.
+func ConvertOTLP(data *logsv1.LogsData, now time.Time) []storage.LogEntry {
+	var entries []storage.LogEntry
+
+	for _, resourceLogs := range data.GetResourceLogs() {
+		resourceAttrs := attributesToMap(resourceLogs.GetResource().GetAttributes())
+		source, _ := resourceAttrs["service.name"].(string)
+
+		for _, scopeLogs := range resourceLogs.GetScopeLogs() {
+			scope := scopeLogs.GetScope()
+
+			for _, record := range scopeLogs.GetLogRecords() {
+				fields := make(map[string]interface{}, len(resourceAttrs)+len(record.GetAttributes())+4)
+				for k, v := range resourceAttrs {
+					fields[k] = v
+				}
+				if scope.GetName() != "" {
+					fields["scope.name"] = scope.GetName()
+				}
+				if scope.GetVersion() != "" {
+					fields["scope.version"] = scope.GetVersion()
+				}
+				for _, kv := range scope.GetAttributes() {
+					fields["scope."+kv.GetKey()] = anyValueToInterface(kv.GetValue())
+				}
+				for _, kv := range record.GetAttributes() {
+					fields[kv.GetKey()] = anyValueToInterface(kv.GetValue())
+				}
+
+				if id := record.GetTraceId(); len(id) > 0 {
+					fields["trace_id"] = hex.EncodeToString(id)
+				}
+				if id := record.GetSpanId(); len(id) > 0 {
+					fields["span_id"] = hex.EncodeToString(id)
+				}
+				if record.GetSeverityNumber() != logsv1.SeverityNumber_SEVERITY_NUMBER_UNSPECIFIED {
+					fields["severity_number"] = int32(record.GetSeverityNumber())
+				}
+				if record.GetEventName() != "" {
+					fields["event.name"] = record.GetEventName()
+				}
+
+				entrySource := source
+				if entrySource == "" {
+					entrySource = scope.GetName()
+				}
+				if entrySource == "" {
+					entrySource = "otlp"
+				}
+
+				entries = append(entries, storage.LogEntry{
+					Timestamp: otlpTimestamp(record, now),
+					Level:     otlpLevel(record),
+					Message:   anyValueToString(record.GetBody()),
+					Source:    entrySource,
+					Fields:    fields,
+				})
+			}
+		}
+	}
+
+	return entries
+}
+
+func otlpTimestamp(record *logsv1.LogRecord, now time.Time) time.Time {
+	if ts := record.GetTimeUnixNano(); ts > 0 {
+		return time.Unix(0, int64(ts)).UTC()
+	}
+	if ts := record.GetObservedTimeUnixNano(); ts > 0 {
+		return time.Unix(0, int64(ts)).UTC()
+	}
+	return now
+}
+
+// otlpLevel prefers the severity text and falls back to the short name of
+// the severity number range.
+func otlpLevel(record *logsv1.LogRecord) string {
+	if text := record.GetSeverityText(); text != "" {
+		return text
+	}
+
+	switch n := record.GetSeverityNumber(); {
+	case n >= logsv1.SeverityNumber_SEVERITY_NUMBER_FATAL:
+		return "fatal"
+	case n >= logsv1.SeverityNumber_SEVERITY_NUMBER_ERROR:
+		return "error"
+	case n >= logsv1.SeverityNumber_SEVERITY_NUMBER_WARN:
+		return "warn"
+	case n >= logsv1.SeverityNumber_SEVERITY_NUMBER_INFO:
+		return "info"
+	case n >= logsv1.SeverityNumber_SEVERITY_NUMBER_DEBUG:
+		return "debug"
+	case n >= logsv1.SeverityNumber_SEVERITY_NUMBER_TRACE:
+		return "trace"
+	default:
+		return "info"
+	}
+}
+
+func attributesToMap(attrs []*commonv1.KeyValue) map[string]interface{} {
+	result := make(map[string]interface{}, len(attrs))
+	for _, kv := range attrs {
+		result[kv.GetKey()] = anyValueToInterface(kv.GetValue())
+	}
+	return result
+}
+
+func anyValueToInterface(value *commonv1.AnyValue) interface{} {
+	switch v := value.GetValue().(type) {
+	case *commonv1.AnyValue_StringValue:
+		return v.StringValue
+	case *commonv1.AnyValue_BoolValue:
+		return v.BoolValue
+	case *commonv1.AnyValue_IntValue:
+		return v.IntValue
+	case *commonv1.AnyValue_DoubleValue:
+		return v.DoubleValue
+	case *commonv1.AnyValue_BytesValue:
+		return base64.StdEncoding.EncodeToString(v.BytesValue)
+	case *commonv1.AnyValue_ArrayValue:
+		values := make([]interface{}, 0, len(v.ArrayValue.GetValues()))
+		for _, item := range v.ArrayValue.GetValues() {
+			values = append(values, anyValueToInterface(item))
+		}
+		return values
+	case *commonv1.AnyValue_KvlistValue:
+		return attributesToMap(v.KvlistValue.GetValues())
+	default:
+		return nil
+	}
+}
+
+// anyValueToString renders a log body; structured bodies are JSON-encoded.
+func anyValueToString(value *commonv1.AnyValue) string {
+	if v, ok := value.GetValue().(*commonv1.AnyValue_StringValue); ok {
+		return v.StringValue
+	}
+
+	decoded := anyValueToInterface(value)
+	if decoded == nil {
+		return ""
+	}
+	encoded, err := json.Marshal(decoded)
+	if err != nil {
+		return fmt.Sprint(decoded)
+	}
+	return string(encoded)
+}
+
+// hexIDsToBase64 rewrites traceId and spanId in every log record.
+func hexIDsToBase64(raw map[string]interface{}) error {
+	for _, resourceLogs := range jsonArray(raw, "resourceLogs", "resource_logs") {
+		for _, scopeLogs := range jsonArray(resourceLogs, "scopeLogs", "scope_logs") {
+			for _, record := range jsonArray(scopeLogs, "logRecords", "log_records") {
+				for _, key := range []string{"traceId", "trace_id", "spanId", "span_id"} {
+					id, ok := record[key].(string)
+					if !ok || id == "" {
+						continue
+					}
+					decoded, err := hex.DecodeString(id)
+					if err != nil {
+						return fmt.Errorf("invalid OTLP JSON payload: %s is not hex", key)
+					}
+					record[key] = base64.StdEncoding.EncodeToString(decoded)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func jsonArray(object map[string]interface{}, keys ...string) []map[string]interface{} {
+	for _, key := range keys {
+		items, ok := object[key].([]interface{})
+		if !ok {
+			continue
+		}
+		result := make([]map[string]interface{}, 0, len(items))
+		for _, item := range items {
+			if m, ok := item.(map[string]interface{}); ok {
+				result = append(result, m)
+			}
+		}
+		return result
+	}
+	return nil
+}
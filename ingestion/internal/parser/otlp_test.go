@@ -0,0 +1,108 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	commonv1 "go.opentelemetry.io/proto/otlp/common/v1"
+	logsv1 "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcev1 "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestDecodeOTLPProtobuf(t *testing.T) {
+	data := &logsv1.LogsData{
+		ResourceLogs: []*logsv1.ResourceLogs{{
+			Resource: &resourcev1.Resource{Attributes: []*commonv1.KeyValue{
+				{Key: "service.name", Value: &commonv1.AnyValue{Value: &commonv1.AnyValue_StringValue{StringValue: "checkout"}}},
+			}},
+			ScopeLogs: []*logsv1.ScopeLogs{{
+				Scope: &commonv1.InstrumentationScope{Name: "app.logger"},
+				LogRecords: []*logsv1.LogRecord{{
+					TimeUnixNano:   1700000000000000000,
+					SeverityNumber: logsv1.SeverityNumber_SEVERITY_NUMBER_ERROR2,
+					Body:           &commonv1.AnyValue{Value: &commonv1.AnyValue_StringValue{StringValue: "charge failed"}},
+					TraceId:        []byte{0x5b, 0x8e, 0xff, 0xf7, 0x98, 0x03, 0x81, 0x03, 0xd2, 0x69, 0xb6, 0x33, 0x81, 0x3f, 0xc6, 0x0c},
+					Attributes: []*commonv1.KeyValue{
+						{Key: "order_id", Value: &commonv1.AnyValue{Value: &commonv1.AnyValue_IntValue{IntValue: 42}}},
+					},
+				}},
+			}},
+		}},
+	}
+
+	body, err := proto.Marshal(data)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+
+	entries, err := DecodeOTLPProtobuf(body, time.Now())
+	if err != nil {
+		t.Fatalf("DecodeOTLPProtobuf failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(entries))
+	}
+
+	entry := entries[0]
+	if entry.Source != "checkout" || entry.Level != "error" || entry.Message != "charge failed" {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+	if entry.Timestamp.UnixNano() != 1700000000000000000 {
+		t.Errorf("Unexpected timestamp: %v", entry.Timestamp)
+	}
+	if entry.Fields["trace_id"] != "5b8efff798038103d269b633813fc60c" || entry.Fields["order_id"] != int64(42) {
+		t.Errorf("Unexpected fields: %v", entry.Fields)
+	}
+	if entry.Fields["scope.name"] != "app.logger" {
+		t.Errorf("Expected scope name field: %v", entry.Fields)
+	}
+}
+
+func TestDecodeOTLPJSON(t *testing.T) {
+	body := `{"resourceLogs":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"api"}}]},
+		"scopeLogs":[{"logRecords":[{"timeUnixNano":"1700000000000000001","severityText":"WARN",
+		"traceId":"5b8efff798038103d269b633813fc60c","spanId":"eee19b7ec3c1b174",
+		"body":{"kvlistValue":{"values":[{"key":"event","value":{"stringValue":"retry"}}]}}}]}]}]}`
+
+	entries, err := DecodeOTLPJSON([]byte(body), time.Now())
+	if err != nil {
+		t.Fatalf("DecodeOTLPJSON failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(entries))
+	}
+
+	entry := entries[0]
+	if entry.Level != "WARN" || entry.Message != `{"event":"retry"}` {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+	if entry.Timestamp.UnixNano() != 1700000000000000001 {
+		t.Errorf("Timestamp lost precision: %d", entry.Timestamp.UnixNano())
+	}
+	if entry.Fields["span_id"] != "eee19b7ec3c1b174" {
+		t.Errorf("Unexpected span_id: %v", entry.Fields["span_id"])
+	}
+}
+
+func TestEncodeOTLPResponseProtobuf(t *testing.T) {
+	if response := EncodeOTLPResponseProtobuf(0, ""); len(response) != 0 {
+		t.Errorf("Expected an empty response for full success, got %x", response)
+	}
+
+	response := EncodeOTLPResponseProtobuf(2, "too many fields")
+	num, typ, n := protowire.ConsumeTag(response)
+	if num != 1 || typ != protowire.BytesType {
+		t.Fatalf("Expected partial_success, got field %d", num)
+	}
+	partial, _ := protowire.ConsumeBytes(response[n:])
+	_, _, n = protowire.ConsumeTag(partial)
+	rejected, m := protowire.ConsumeVarint(partial[n:])
+	partial = partial[n+m:]
+	_, _, n = protowire.ConsumeTag(partial)
+	message, _ := protowire.ConsumeString(partial[n:])
+	if rejected != 2 || message != "too many fields" {
+		t.Errorf("Unexpected partial_success: %d %q", rejected, message)
+	}
+}
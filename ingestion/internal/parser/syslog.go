@@ -0,0 +1,232 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+)
+
+// ErrInvalidSyslog is returned for messages without a valid PRI header.
+var ErrInvalidSyslog = errors.New("invalid syslog message")
+
+var syslogSeverities = []string{
+	"emergency", "alert", "critical", "error", "warning", "notice", "info", "debug",
+}
+
+var syslogFacilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// ParseSyslog parses an RFC 5424 or RFC 3164 message. now is used for
+// RFC 3164 timestamps, which carry no year, and for messages without a
+// timestamp.
+func ParseSyslog(msg []byte, now time.Time) (storage.LogEntry, error) {
+	msg = bytes.TrimRight(msg, "\r\n\x00")
+
+	pri, rest, err := parsePriority(msg)
+	if err != nil {
+		return storage.LogEntry{}, err
+	}
+
+	facility, severity := pri/8, pri%8
+	fields := map[string]interface{}{
+		"facility": facilityName(facility),
+		"severity": severity,
+	}
+
+	var entry storage.LogEntry
+	if len(rest) > 1 && rest[0] >= '1' && rest[0] <= '9' && rest[1] == ' ' {
+		entry = parseRFC5424(string(rest[2:]), now, fields)
+	} else {
+		entry = parseRFC3164(string(rest), now, fields)
+	}
+
+	entry.Level = syslogSeverities[severity]
+	entry.Fields = fields
+	if entry.Source == "" {
+		entry.Source = "syslog"
+	}
+
+	return entry, nil
+}
+
+func parsePriority(msg []byte) (int, []byte, error) {
+	if len(msg) < 3 || msg[0] != '<' {
+		return 0, nil, ErrInvalidSyslog
+	}
+
+	end := bytes.IndexByte(msg[:min(len(msg), 5)], '>')
+	if end < 2 {
+		return 0, nil, ErrInvalidSyslog
+	}
+
+	pri, err := strconv.Atoi(string(msg[1:end]))
+	if err != nil || pri < 0 || pri > 191 {
+		return 0, nil, fmt.Errorf("%w: bad priority %q", ErrInvalidSyslog, msg[1:end])
+	}
+
+	return pri, msg[end+1:], nil
+}
+
+// parseRFC5424 parses everything after "<PRI>VERSION ".
+func parseRFC5424(rest string, now time.Time, fields map[string]interface{}) storage.LogEntry {
+	entry := storage.LogEntry{Timestamp: now}
+
+	timestamp, rest := nextToken(rest)
+	hostname, rest := nextToken(rest)
+	appName, rest := nextToken(rest)
+	procID, rest := nextToken(rest)
+	msgID, rest := nextToken(rest)
+
+	if timestamp != "-" {
+		if ts, err := time.Parse(time.RFC3339Nano, timestamp); err == nil {
+			entry.Timestamp = ts
+		}
+	}
+	setNonNil(fields, "hostname", hostname)
+	setNonNil(fields, "app_name", appName)
+	setNonNil(fields, "proc_id", procID)
+	setNonNil(fields, "msg_id", msgID)
+
+	rest = parseStructuredData(rest, fields)
+
+	rest = strings.TrimPrefix(rest, " ")
+	rest = strings.TrimPrefix(rest, "\ufeff")
+	entry.Message = rest
+
+	entry.Source = nonNil(appName)
+	if entry.Source == "" {
+		entry.Source = nonNil(hostname)
+	}
+
+	return entry
+}
+
+// parseStructuredData consumes SD-ELEMENTs, storing each parameter as
+// "<sd-id>.<param>", and returns the remaining message.
+func parseStructuredData(rest string, fields map[string]interface{}) string {
+	if strings.HasPrefix(rest, "-") {
+		return rest[1:]
+	}
+
+	for strings.HasPrefix(rest, "[") {
+		end := strings.IndexAny(rest, " ]")
+		if end < 0 {
+			return rest
+		}
+		id := rest[1:end]
+		rest = rest[end:]
+
+		for strings.HasPrefix(rest, " ") {
+			rest = rest[1:]
+			eq := strings.Index(rest, "=\"")
+			if eq < 0 {
+				return rest
+			}
+			name := rest[:eq]
+			rest = rest[eq+2:]
+
+			var value strings.Builder
+			i := 0
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) && strings.IndexByte(`"\]`, rest[i+1]) >= 0 {
+					i++
+				}
+				value.WriteByte(rest[i])
+			}
+			if i >= len(rest) {
+				return ""
+			}
+			fields[id+"."+name] = value.String()
+			rest = rest[i+1:]
+		}
+
+		if !strings.HasPrefix(rest, "]") {
+			return rest
+		}
+		rest = rest[1:]
+	}
+
+	return rest
+}
+
+// parseRFC3164 parses everything after "<PRI>". The format is loosely
+// specified, so missing timestamps and hostnames are tolerated.
+func parseRFC3164(rest string, now time.Time, fields map[string]interface{}) storage.LogEntry {
+	entry := storage.LogEntry{Timestamp: now}
+
+	if len(rest) >= 15 {
+		if ts, err := time.ParseInLocation(time.Stamp, rest[:15], now.Location()); err == nil {
+			ts = ts.AddDate(now.Year(), 0, 0)
+			// A timestamp in the future belongs to the previous year
+			if ts.After(now.Add(24 * time.Hour)) {
+				ts = ts.AddDate(-1, 0, 0)
+			}
+			entry.Timestamp = ts
+			rest = strings.TrimPrefix(rest[15:], " ")
+
+			// A hostname follows the timestamp unless the tag comes first
+			if host, remainder := nextToken(rest); host != "" && !strings.ContainsAny(host, ":[") {
+				fields["hostname"] = host
+				rest = remainder
+			}
+		}
+	}
+
+	// TAG[PID]: MSG
+	tag, message := "", rest
+	if colon := strings.Index(rest, ": "); colon > 0 && !strings.Contains(rest[:colon], " ") {
+		tag, message = rest[:colon], rest[colon+2:]
+	}
+	if open := strings.IndexByte(tag, '['); open > 0 && strings.HasSuffix(tag, "]") {
+		fields["proc_id"] = tag[open+1 : len(tag)-1]
+		tag = tag[:open]
+	}
+	if tag != "" {
+		fields["app_name"] = tag
+	}
+
+	entry.Message = message
+	entry.Source = tag
+	if entry.Source == "" {
+		if host, ok := fields["hostname"].(string); ok {
+			entry.Source = host
+		}
+	}
+
+	return entry
+}
+
+func nextToken(s string) (string, string) {
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, ""
+}
+
+func nonNil(value string) string {
+	if value == "-" {
+		return ""
+	}
+	return value
+}
+
+func setNonNil(fields map[string]interface{}, key, value string) {
+	if value = nonNil(value); value != "" {
+		fields[key] = value
+	}
+}
+
+func facilityName(facility int) string {
+	if facility < len(syslogFacilities) {
+		return syslogFacilities[facility]
+	}
+	return strconv.Itoa(facility)
+}
@@ -0,0 +1,52 @@
+package parser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSyslogRFC5424(t *testing.T) {
+	msg := `<165>1 2003-10-11T22:14:15.003Z mymachine.example.com evntslog 42 ID47 [exampleSDID@32473 iut="3" eventSource="Appl\"ication"] An application event`
+
+	entry, err := ParseSyslog([]byte(msg), time.Now())
+	if err != nil {
+		t.Fatalf("ParseSyslog failed: %v", err)
+	}
+
+	if entry.Level != "notice" || entry.Source != "evntslog" || entry.Message != "An application event" {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+	if !entry.Timestamp.Equal(time.Date(2003, 10, 11, 22, 14, 15, 3000000, time.UTC)) {
+		t.Errorf("Unexpected timestamp: %v", entry.Timestamp)
+	}
+	if entry.Fields["hostname"] != "mymachine.example.com" || entry.Fields["facility"] != "local4" {
+		t.Errorf("Unexpected header fields: %v", entry.Fields)
+	}
+	if entry.Fields["exampleSDID@32473.eventSource"] != `Appl"ication` || entry.Fields["exampleSDID@32473.iut"] != "3" {
+		t.Errorf("Unexpected structured data: %v", entry.Fields)
+	}
+}
+
+func TestParseSyslogRFC3164(t *testing.T) {
+	now := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	entry, err := ParseSyslog([]byte("<34>Dec 31 22:14:15 mymachine su[123]: 'su root' failed\n"), now)
+	if err != nil {
+		t.Fatalf("ParseSyslog failed: %v", err)
+	}
+
+	if entry.Level != "critical" || entry.Source != "su" || entry.Message != "'su root' failed" {
+		t.Errorf("Unexpected entry: %+v", entry)
+	}
+	if entry.Timestamp.Year() != 2023 {
+		t.Errorf("Expected timestamp in the previous year, got %v", entry.Timestamp)
+	}
+	if entry.Fields["hostname"] != "mymachine" || entry.Fields["proc_id"] != "123" {
+		t.Errorf("Unexpected fields: %v", entry.Fields)
+	}
+}
+
+func TestParseSyslogRejectsMissingPriority(t *testing.T) {
+	if _, err := ParseSyslog([]byte("just some text"), time.Now()); err == nil {
+		t.Error("Expected an error for a message without PRI")
+	}
+}
@@ -0,0 +1,182 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// grokReference matches %{PATTERN}, %{PATTERN:field} and
+// %{PATTERN:field:type}.
+var grokReference = regexp.MustCompile(`%\{(\w+)(?::([\w.@\[\]-]+))?(?::(int|float))?\}`)
+
+// grokPatterns is the built-in pattern library: a subset of the common
+// Logstash base patterns, plus whole-line patterns for nginx, Apache and
+// Postgres logs.
+var grokPatterns = map[string]string{
+	"USERNAME":          `[a-zA-Z0-9._-]+`,
+	"USER":              `%{USERNAME}`,
+	"INT":               `[+-]?[0-9]+`,
+	"BASE10NUM":         `[+-]?(?:[0-9]+(?:\.[0-9]+)?|\.[0-9]+)`,
+	"NUMBER":            `%{BASE10NUM}`,
+	"POSINT":            `\b[1-9][0-9]*\b`,
+	"NONNEGINT":         `\b[0-9]+\b`,
+	"WORD":              `\b\w+\b`,
+	"NOTSPACE":          `\S+`,
+	"SPACE":             `\s*`,
+	"DATA":              `.*?`,
+	"GREEDYDATA":        `.*`,
+	"QUOTEDSTRING":      `"(?:[^"\\]|\\.)*"`,
+	"UUID":              `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`,
+	"IPV4":              `(?:(?:25[0-5]|2[0-4][0-9]|1?[0-9]{1,2})\.){3}(?:25[0-5]|2[0-4][0-9]|1?[0-9]{1,2})`,
+	"IPV6":              `(?:[0-9A-Fa-f]{0,4}:){2,7}[0-9A-Fa-f]{0,4}(?:%[0-9A-Za-z]+)?`,
+	"IP":                `(?:%{IPV6}|%{IPV4})`,
+	"HOSTNAME":          `\b[0-9A-Za-z][0-9A-Za-z-]{0,62}(?:\.[0-9A-Za-z][0-9A-Za-z-]{0,62})*\.?`,
+	"IPORHOST":          `(?:%{IP}|%{HOSTNAME})`,
+	"HOSTPORT":          `%{IPORHOST}:%{POSINT}`,
+	"URIPATHPARAM":      `\S+`,
+	"MONTH":             `\b(?:Jan(?:uary)?|Feb(?:ruary)?|Mar(?:ch)?|Apr(?:il)?|May|June?|July?|Aug(?:ust)?|Sep(?:tember)?|Oct(?:ober)?|Nov(?:ember)?|Dec(?:ember)?)\b`,
+	"MONTHNUM":          `(?:0?[1-9]|1[0-2])`,
+	"MONTHDAY":          `(?:0[1-9]|[12][0-9]|3[01]|[1-9])`,
+	"DAY":               `\b(?:Mon(?:day)?|Tue(?:sday)?|Wed(?:nesday)?|Thu(?:rsday)?|Fri(?:day)?|Sat(?:urday)?|Sun(?:day)?)\b`,
+	"YEAR":              `(?:\d\d){1,2}`,
+	"HOUR":              `(?:2[0123]|[01]?[0-9])`,
+	"MINUTE":            `[0-5][0-9]`,
+	"SECOND":            `(?:[0-5]?[0-9]|60)(?:[:.,][0-9]+)?`,
+	"TIME":              `%{HOUR}:%{MINUTE}(?::%{SECOND})?`,
+	"TZ":                `[A-Z]{3,5}`,
+	"ISO8601_TIMEZONE":  `(?:Z|[+-]%{HOUR}(?::?%{MINUTE}))`,
+	"TIMESTAMP_ISO8601": `%{YEAR}-%{MONTHNUM}-%{MONTHDAY}[T ]%{HOUR}:?%{MINUTE}(?::?%{SECOND})?%{ISO8601_TIMEZONE}?`,
+	"HTTPDATE":          `%{MONTHDAY}/%{MONTH}/%{YEAR}:%{TIME} %{INT}`,
+	"LOGLEVEL":          `(?:[Tt]race|TRACE|[Dd]ebug|DEBUG|[Nn]otice|NOTICE|[Ii]nfo|INFO|[Ww]arn(?:ing)?|WARN(?:ING)?|[Ee]rr(?:or)?|ERR(?:OR)?|[Cc]rit(?:ical)?|CRIT(?:ICAL)?|[Aa]lert|ALERT|[Ee]merg(?:ency)?|EMERG(?:ENCY)?|[Ff]atal|FATAL)`,
+
+	"NGINX_ACCESS":      `%{IPORHOST:client_ip} - %{NOTSPACE:remote_user} \[%{HTTPDATE:timestamp}\] "%{WORD:method} %{NOTSPACE:path} HTTP/%{NUMBER:http_version}" %{INT:status:int} %{INT:body_bytes:int} "%{DATA:referrer}" "%{DATA:user_agent}"`,
+	"NGINX_ERROR_TIME":  `%{YEAR}/%{MONTHNUM}/%{MONTHDAY} %{TIME}`,
+	"NGINX_ERROR":       `%{NGINX_ERROR_TIME:timestamp} \[%{LOGLEVEL:level}\] %{POSINT:pid:int}#%{NONNEGINT:tid:int}: (?:\*%{NONNEGINT:connection_id:int} )?%{GREEDYDATA:message}`,
+	"APACHE_COMMON":     `%{IPORHOST:client_ip} %{NOTSPACE:ident} %{NOTSPACE:remote_user} \[%{HTTPDATE:timestamp}\] "%{WORD:method} %{NOTSPACE:path} HTTP/%{NUMBER:http_version}" %{INT:status:int} (?:%{INT:body_bytes:int}|-)`,
+	"APACHE_COMBINED":   `%{APACHE_COMMON} "%{DATA:referrer}" "%{DATA:user_agent}"`,
+	"APACHE_ERROR_TIME": `%{DAY} %{MONTH} %{MONTHDAY} %{TIME} %{YEAR}`,
+	"APACHE_ERROR":      `\[%{APACHE_ERROR_TIME:timestamp}\] \[(?:%{WORD:module}:)?%{LOGLEVEL:level}\] (?:\[pid %{POSINT:pid:int}(?::tid %{INT:tid:int})?\] )?(?:\[client %{IPORHOST:client_ip}(?::%{POSINT:client_port:int})?\] )?%{GREEDYDATA:message}`,
+	"POSTGRES_TIME":     `%{YEAR}-%{MONTHNUM}-%{MONTHDAY} %{TIME}(?: %{TZ})?`,
+	"POSTGRES_LEVEL":    `(?:LOG|INFO|NOTICE|WARNING|ERROR|FATAL|PANIC|DEBUG[1-5]|STATEMENT|DETAIL|HINT|CONTEXT)`,
+	"POSTGRES":          `%{POSTGRES_TIME:timestamp} \[%{POSINT:pid:int}\] (?:%{NOTSPACE:user}@%{NOTSPACE:database} )?%{POSTGRES_LEVEL:level}:\s+%{GREEDYDATA:message}`,
+}
+
+// grokCompiler expands grok expressions into regular expressions using the
+// built-in library and any configured patterns.
+type grokCompiler struct {
+	patterns map[string]string
+}
+
+func newGrokCompiler(custom map[string]string) (*grokCompiler, error) {
+	patterns := make(map[string]string, len(grokPatterns)+len(custom))
+	for name, pattern := range grokPatterns {
+		patterns[name] = pattern
+	}
+	for name, pattern := range custom {
+		patterns[name] = pattern
+	}
+
+	g := &grokCompiler{patterns: patterns}
+	for name := range custom {
+		if _, err := g.expand(patterns[name], nil, map[string]bool{name: true}); err != nil {
+			return nil, fmt.Errorf("grok pattern %s: %w", name, err)
+		}
+	}
+	return g, nil
+}
+
+// compile anchors a grok expression to the whole message.
+func (g *grokCompiler) compile(expression string) (*captureProcessor, error) {
+	if expression == "" {
+		return nil, fmt.Errorf("grok processor requires a pattern")
+	}
+
+	var fields []captureField
+	expanded, err := g.expand(expression, &fields, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := regexp.Compile("^(?:" + expanded + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid grok expression %q: %w", expression, err)
+	}
+
+	// Group indexes follow the order of the generated group names
+	names := re.SubexpNames()
+	for i := range fields {
+		for j, name := range names {
+			if name == grokGroupName(i) {
+				fields[i].index = j
+			}
+		}
+	}
+
+	return &captureProcessor{re: re, fields: fields}, nil
+}
+
+// expand replaces pattern references recursively. Named references become
+// groups called g0, g1, ..., since field names need not be valid group
+// names; fields records what each of them maps to.
+func (g *grokCompiler) expand(expression string, fields *[]captureField, active map[string]bool) (string, error) {
+	var expandErr error
+	expanded := grokReference.ReplaceAllStringFunc(expression, func(ref string) string {
+		if expandErr != nil {
+			return ""
+		}
+
+		parts := grokReference.FindStringSubmatch(ref)
+		name, field, convert := parts[1], parts[2], parts[3]
+
+		pattern, ok := g.patterns[name]
+		if !ok {
+			expandErr = fmt.Errorf("unknown grok pattern %q", name)
+			return ""
+		}
+		if active[name] {
+			expandErr = fmt.Errorf("grok pattern %q is recursive", name)
+			return ""
+		}
+
+		active[name] = true
+		inner, err := g.expand(pattern, fields, active)
+		delete(active, name)
+		if err != nil {
+			expandErr = err
+			return ""
+		}
+
+		if field == "" || fields == nil {
+			return "(?:" + inner + ")"
+		}
+		group := grokGroupName(len(*fields))
+		*fields = append(*fields, captureField{name: field, convert: convert})
+		return "(?P<" + group + ">" + inner + ")"
+	})
+	if expandErr != nil {
+		return "", expandErr
+	}
+	return expanded, nil
+}
+
+func grokGroupName(i int) string {
+	return "g" + strconv.Itoa(i)
+}
+
+// convertValue applies a grok int or float conversion, keeping the string
+// when it does not parse.
+func convertValue(value, convert string) interface{} {
+	switch convert {
+	case "int":
+		if n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil {
+			return n
+		}
+	case "float":
+		if f, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+			return f
+		}
+	}
+	return value
+}
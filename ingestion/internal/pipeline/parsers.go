@@ -0,0 +1,229 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+)
+
+var (
+	messageKeys = []string{"message", "msg"}
+	levelKeys   = []string{"level", "severity"}
+)
+
+// mergeFields adds parsed values to the entry. A string message or level
+// replaces the entry's own; other values never overwrite existing fields.
+func mergeFields(entry *storage.LogEntry, values map[string]interface{}) {
+	for _, key := range messageKeys {
+		if message, ok := values[key].(string); ok && message != "" {
+			entry.Message = message
+			delete(values, key)
+			break
+		}
+	}
+	for _, key := range levelKeys {
+		if level, ok := values[key].(string); ok && level != "" {
+			entry.Level = level
+			delete(values, key)
+			break
+		}
+	}
+
+	if len(values) == 0 {
+		return
+	}
+	if entry.Fields == nil {
+		entry.Fields = make(map[string]interface{}, len(values))
+	}
+	for key, value := range values {
+		if _, exists := entry.Fields[key]; !exists {
+			entry.Fields[key] = value
+		}
+	}
+}
+
+// jsonProcessor expands a message holding a JSON object.
+type jsonProcessor struct{}
+
+func (jsonProcessor) Process(entry *storage.LogEntry) bool {
+	message := strings.TrimSpace(entry.Message)
+	if !strings.HasPrefix(message, "{") || !strings.HasSuffix(message, "}") {
+		return false
+	}
+
+	var values map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(message))
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err != nil {
+		return false
+	}
+
+	mergeFields(entry, values)
+	return true
+}
+
+// logfmtProcessor expands a message of key=value pairs.
+type logfmtProcessor struct{}
+
+func (logfmtProcessor) Process(entry *storage.LogEntry) bool {
+	values, ok := parseLogfmt(entry.Message)
+	if !ok {
+		return false
+	}
+
+	mergeFields(entry, values)
+	return true
+}
+
+// parseLogfmt parses key=value pairs with optionally quoted values. Bare
+// keys are true. The message must contain at least one key=value pair and
+// nothing else.
+func parseLogfmt(message string) (map[string]interface{}, bool) {
+	values := make(map[string]interface{})
+	pairs := 0
+	s := strings.TrimSpace(message)
+	for len(s) > 0 {
+		end := strings.IndexAny(s, "= \t")
+		if end < 0 {
+			end = len(s)
+		}
+		key := s[:end]
+		if !isLogfmtKey(key) {
+			return nil, false
+		}
+		s = s[end:]
+
+		if !strings.HasPrefix(s, "=") {
+			values[key] = true
+			s = strings.TrimLeft(s, " \t")
+			continue
+		}
+		s = s[1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			quoted, rest, ok := cutQuoted(s)
+			if !ok {
+				return nil, false
+			}
+			value, s = quoted, rest
+			if len(s) > 0 && s[0] != ' ' && s[0] != '\t' {
+				return nil, false
+			}
+		} else {
+			end := strings.IndexAny(s, " \t")
+			if end < 0 {
+				end = len(s)
+			}
+			value, s = s[:end], s[end:]
+			if strings.ContainsAny(value, `="`) {
+				return nil, false
+			}
+		}
+
+		values[key] = value
+		pairs++
+		s = strings.TrimLeft(s, " \t")
+	}
+
+	return values, pairs > 0
+}
+
+func isLogfmtKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, r := range key {
+		if !(r == '_' || r == '.' || r == '-' || r == '/' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+// cutQuoted unquotes the leading double-quoted string of s.
+func cutQuoted(s string) (string, string, bool) {
+	var buf bytes.Buffer
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 >= len(s) {
+				return "", "", false
+			}
+			i++
+			switch s[i] {
+			case 'n':
+				buf.WriteByte('\n')
+			case 't':
+				buf.WriteByte('\t')
+			case 'r':
+				buf.WriteByte('\r')
+			default:
+				buf.WriteByte(s[i])
+			}
+		case '"':
+			return buf.String(), s[i+1:], true
+		default:
+			buf.WriteByte(s[i])
+		}
+	}
+	return "", "", false
+}
+
+// captureProcessor extracts the named groups of a regular expression.
+// Groups named message or level replace the entry's own.
+type captureProcessor struct {
+	re *regexp.Regexp
+	// fields maps group indexes to field names and optional conversions.
+	fields []captureField
+}
+
+type captureField struct {
+	index   int
+	name    string
+	convert string
+}
+
+func newRegexProcessor(pattern string) (*captureProcessor, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("regex processor requires a pattern")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex: %w", err)
+	}
+
+	p := &captureProcessor{re: re}
+	for i, name := range re.SubexpNames() {
+		if name != "" {
+			p.fields = append(p.fields, captureField{index: i, name: name})
+		}
+	}
+	if len(p.fields) == 0 {
+		return nil, fmt.Errorf("regex %q has no named groups", pattern)
+	}
+	return p, nil
+}
+
+func (p *captureProcessor) Process(entry *storage.LogEntry) bool {
+	match := p.re.FindStringSubmatchIndex(entry.Message)
+	if match == nil {
+		return false
+	}
+
+	values := make(map[string]interface{}, len(p.fields))
+	for _, field := range p.fields {
+		start, end := match[2*field.index], match[2*field.index+1]
+		if start < 0 {
+			continue
+		}
+		values[field.name] = convertValue(entry.Message[start:end], field.convert)
+	}
+
+	mergeFields(entry, values)
+	return true
+}
@@ -0,0 +1,285 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+)
+
+// maxCachedSources bounds the source to pipeline lookup cache.
+const maxCachedSources = 10000
+
+// Config is the processing configuration, usually loaded from a JSON file.
+type Config struct {
+	// Patterns defines additional grok patterns by name.
+	Patterns map[string]string `json:"patterns,omitempty"`
+	// Pipelines are matched against the entry source in order; the first
+	// match is applied.
+	Pipelines []PipelineConfig `json:"pipelines"`
+	// Rules drop or sample entries of every project without its own rules;
+	// the first matching rule applies.
+	Rules []RuleConfig `json:"rules,omitempty"`
+	// Redaction applies to every project without its own setting.
+	Redaction *RedactionConfig `json:"redaction,omitempty"`
+	// Tags are static fields added to every entry.
+	Tags map[string]string `json:"tags,omitempty"`
+	// Projects overrides settings per project ID.
+	Projects map[string]ProjectConfig `json:"projects,omitempty"`
+}
+
+// ProjectConfig holds per-project settings. Unset fields fall back to the
+// top-level ones.
+type ProjectConfig struct {
+	Rules     []RuleConfig     `json:"rules,omitempty"`
+	Redaction *RedactionConfig `json:"redaction,omitempty"`
+	// Tags are added to the top-level tags, replacing those with the same
+	// name.
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// PipelineConfig is a processor chain and the sources it applies to.
+type PipelineConfig struct {
+	Name string `json:"name"`
+	// Sources are glob patterns, as in path.Match, matched against the
+	// entry source.
+	Sources    []string          `json:"sources"`
+	Processors []ProcessorConfig `json:"processors"`
+}
+
+// ProcessorConfig configures one processor. Type is json, logfmt, regex
+// or grok; regex and grok require a Pattern.
+type ProcessorConfig struct {
+	Type    string `json:"type"`
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// Processor parses or transforms a single entry in place. It reports
+// whether it applied; entries it cannot handle are left untouched, so the
+// original message is never lost.
+type Processor interface {
+	Process(entry *storage.LogEntry) bool
+}
+
+// Pipeline applies the processor chain selected by each entry's source,
+// then the stages configured for its project.
+type Pipeline struct {
+	chains   []chain
+	defaults stages
+	projects map[int]stages
+	random   func() float64
+	dropped  atomic.Int64
+
+	mu    sync.RWMutex
+	cache map[string]int
+}
+
+type chain struct {
+	name       string
+	sources    []string
+	processors []Processor
+}
+
+// stages are applied per project after parsing.
+type stages struct {
+	rules    []rule
+	redactor *redactor
+	tags     map[string]string
+}
+
+// Stats counts entries dropped by rules, including those not sampled.
+type Stats struct {
+	Dropped int64 `json:"dropped"`
+}
+
+// Load reads a Config from a JSON file.
+func Load(filename string) (*Config, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pipeline config: %w", err)
+	}
+
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse pipeline config %s: %w", filename, err)
+	}
+	return &cfg, nil
+}
+
+// New compiles a Config into a Pipeline.
+func New(cfg *Config) (*Pipeline, error) {
+	grok, err := newGrokCompiler(cfg.Patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Pipeline{cache: make(map[string]int), random: rand.Float64}
+	for i, pc := range cfg.Pipelines {
+		name := pc.Name
+		if name == "" {
+			name = fmt.Sprintf("pipeline %d", i+1)
+		}
+
+		for _, source := range pc.Sources {
+			if _, err := path.Match(source, ""); err != nil {
+				return nil, fmt.Errorf("%s: invalid source pattern %q: %w", name, source, err)
+			}
+		}
+
+		c := chain{name: name, sources: pc.Sources}
+		for j, proc := range pc.Processors {
+			processor, err := newProcessor(proc, grok)
+			if err != nil {
+				return nil, fmt.Errorf("%s: processor %d: %w", name, j+1, err)
+			}
+			c.processors = append(c.processors, processor)
+		}
+		p.chains = append(p.chains, c)
+	}
+
+	if p.defaults.rules, err = newRules(cfg.Rules); err != nil {
+		return nil, err
+	}
+	if p.defaults.redactor, err = newRedactor(cfg.Redaction); err != nil {
+		return nil, fmt.Errorf("redaction: %w", err)
+	}
+	p.defaults.tags = cfg.Tags
+
+	p.projects = make(map[int]stages, len(cfg.Projects))
+	for key, pc := range cfg.Projects {
+		projectID, err := strconv.Atoi(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid project ID %q", key)
+		}
+
+		s := p.defaults
+		if pc.Rules != nil {
+			if s.rules, err = newRules(pc.Rules); err != nil {
+				return nil, fmt.Errorf("project %d: %w", projectID, err)
+			}
+		}
+		if pc.Redaction != nil {
+			if s.redactor, err = newRedactor(pc.Redaction); err != nil {
+				return nil, fmt.Errorf("project %d: redaction: %w", projectID, err)
+			}
+		}
+		if len(pc.Tags) > 0 {
+			s.tags = make(map[string]string, len(cfg.Tags)+len(pc.Tags))
+			for name, value := range cfg.Tags {
+				s.tags[name] = value
+			}
+			for name, value := range pc.Tags {
+				s.tags[name] = value
+			}
+		}
+		p.projects[projectID] = s
+	}
+
+	return p, nil
+}
+
+func newProcessor(cfg ProcessorConfig, grok *grokCompiler) (Processor, error) {
+	switch cfg.Type {
+	case "json":
+		return jsonProcessor{}, nil
+	case "logfmt":
+		return logfmtProcessor{}, nil
+	case "regex":
+		return newRegexProcessor(cfg.Pattern)
+	case "grok":
+		return grok.compile(cfg.Pattern)
+	default:
+		return nil, fmt.Errorf("unknown processor type %q", cfg.Type)
+	}
+}
+
+// Process runs each entry through the chain for its source, then applies
+// its project's drop and sampling rules, redaction and tags. It returns the
+// kept entries, reusing the backing array of logs.
+func (p *Pipeline) Process(logs []storage.LogEntry) []storage.LogEntry {
+	kept := logs[:0]
+	for i := range logs {
+		entry := logs[i]
+		if c := p.chainFor(entry.Source); c != nil {
+			for _, processor := range c.processors {
+				processor.Process(&entry)
+			}
+		}
+
+		s := p.stagesFor(entry.ProjectID)
+		if !applyRules(s.rules, &entry, p.random) {
+			p.dropped.Add(1)
+			continue
+		}
+		if s.redactor != nil {
+			s.redactor.redact(&entry)
+		}
+		addTags(&entry, s.tags)
+		kept = append(kept, entry)
+	}
+	return kept
+}
+
+// Stats returns the number of entries dropped so far.
+func (p *Pipeline) Stats() Stats {
+	return Stats{Dropped: p.dropped.Load()}
+}
+
+// addTags sets static tags on an entry, keeping fields the entry already
+// has.
+func addTags(entry *storage.LogEntry, tags map[string]string) {
+	if len(tags) == 0 {
+		return
+	}
+	if entry.Fields == nil {
+		entry.Fields = make(map[string]interface{}, len(tags))
+	}
+	for name, value := range tags {
+		if _, exists := entry.Fields[name]; !exists {
+			entry.Fields[name] = value
+		}
+	}
+}
+
+func (p *Pipeline) stagesFor(projectID int) stages {
+	if s, ok := p.projects[projectID]; ok {
+		return s
+	}
+	return p.defaults
+}
+
+func (p *Pipeline) chainFor(source string) *chain {
+	p.mu.RLock()
+	index, ok := p.cache[source]
+	p.mu.RUnlock()
+
+	if !ok {
+		index = -1
+	search:
+		for i, c := range p.chains {
+			for _, pattern := range c.sources {
+				if matched, _ := path.Match(pattern, source); matched {
+					index = i
+					break search
+				}
+			}
+		}
+
+		p.mu.Lock()
+		if len(p.cache) < maxCachedSources {
+			p.cache[source] = index
+		}
+		p.mu.Unlock()
+	}
+
+	if index < 0 {
+		return nil
+	}
+	return &p.chains[index]
+}
@@ -0,0 +1,117 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+)
+
+func TestPipelineSelectsChainBySource(t *testing.T) {
+	p, err := New(&Config{Pipelines: []PipelineConfig{
+		{Name: "nginx", Sources: []string{"nginx", "ingress-*"}, Processors: []ProcessorConfig{{Type: "grok", Pattern: "%{NGINX_ACCESS}"}}},
+		{Name: "default", Sources: []string{"*"}, Processors: []ProcessorConfig{{Type: "json"}, {Type: "logfmt"}}},
+	}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	access := `10.0.0.1 - - [10/Oct/2024:13:55:36 +0000] "GET /health HTTP/1.1" 200 612 "-" "curl/8.0"`
+	logs := []storage.LogEntry{
+		{Source: "ingress-public", Level: "info", Message: access},
+		{Source: "api", Level: "info", Message: `{"msg":"user created","level":"warn","user_id":7}`},
+		{Source: "worker", Level: "info", Message: `msg="job done" duration=5ms retried`},
+		{Source: "worker", Level: "info", Message: "plain text is kept", Fields: map[string]interface{}{"k": "v"}},
+		{Source: "nginx", Level: "info", Message: "not an access log line"},
+	}
+	p.Process(logs)
+
+	if logs[0].Message != access || logs[0].Fields["status"] != int64(200) || logs[0].Fields["client_ip"] != "10.0.0.1" {
+		t.Errorf("Unexpected nginx entry: %+v", logs[0])
+	}
+	if logs[0].Fields["user_agent"] != "curl/8.0" || logs[0].Fields["path"] != "/health" {
+		t.Errorf("Unexpected nginx fields: %v", logs[0].Fields)
+	}
+
+	if logs[1].Message != "user created" || logs[1].Level != "warn" || logs[1].Fields["user_id"] == nil {
+		t.Errorf("Unexpected JSON entry: %+v", logs[1])
+	}
+
+	if logs[2].Message != "job done" || logs[2].Fields["duration"] != "5ms" || logs[2].Fields["retried"] != true {
+		t.Errorf("Unexpected logfmt entry: %+v", logs[2])
+	}
+
+	if logs[3].Message != "plain text is kept" || len(logs[3].Fields) != 1 {
+		t.Errorf("Unparsable message should be untouched: %+v", logs[3])
+	}
+	if logs[4].Message != "not an access log line" || logs[4].Fields != nil {
+		t.Errorf("Unmatched grok should leave the entry untouched: %+v", logs[4])
+	}
+}
+
+func TestGrokLibrary(t *testing.T) {
+	grok, err := newGrokCompiler(map[string]string{"QUEUE": `queue=%{WORD:queue}`})
+	if err != nil {
+		t.Fatalf("newGrokCompiler failed: %v", err)
+	}
+
+	tests := []struct {
+		pattern string
+		message string
+		want    map[string]interface{}
+		level   string
+		text    string
+	}{
+		{
+			pattern: "%{APACHE_COMBINED}",
+			message: `192.168.1.5 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://example.com/" "Mozilla/4.08"`,
+			want:    map[string]interface{}{"remote_user": "frank", "body_bytes": int64(2326), "referrer": "http://example.com/"},
+		},
+		{
+			pattern: "%{POSTGRES}",
+			message: `2024-01-02 03:04:05.678 UTC [4242] app@orders ERROR:  duplicate key value`,
+			want:    map[string]interface{}{"pid": int64(4242), "user": "app", "database": "orders"},
+			level:   "ERROR",
+			text:    "duplicate key value",
+		},
+		{
+			pattern: "%{NGINX_ERROR}",
+			message: `2024/01/02 03:04:05 [error] 31#31: *7 open() "/x" failed`,
+			want:    map[string]interface{}{"pid": int64(31), "connection_id": int64(7)},
+			level:   "error",
+			text:    `open() "/x" failed`,
+		},
+		{
+			pattern: "%{WORD:worker} %{QUEUE} took %{NUMBER:ms:float}ms",
+			message: "w1 queue=emails took 1.5ms",
+			want:    map[string]interface{}{"worker": "w1", "queue": "emails", "ms": 1.5},
+		},
+	}
+
+	for _, tt := range tests {
+		processor, err := grok.compile(tt.pattern)
+		if err != nil {
+			t.Fatalf("compile(%s) failed: %v", tt.pattern, err)
+		}
+
+		entry := storage.LogEntry{Message: tt.message}
+		if !processor.Process(&entry) {
+			t.Errorf("%s did not match %q", tt.pattern, tt.message)
+			continue
+		}
+		for key, value := range tt.want {
+			if entry.Fields[key] != value {
+				t.Errorf("%s: expected %s=%v, got %v", tt.pattern, key, value, entry.Fields[key])
+			}
+		}
+		if tt.level != "" && entry.Level != tt.level {
+			t.Errorf("%s: expected level %q, got %q", tt.pattern, tt.level, entry.Level)
+		}
+		if tt.text != "" && entry.Message != tt.text {
+			t.Errorf("%s: expected message %q, got %q", tt.pattern, tt.text, entry.Message)
+		}
+	}
+
+	if _, err := grok.compile("%{MISSING}"); err == nil {
+		t.Error("Expected an error for an unknown pattern")
+	}
+}
@@ -0,0 +1,308 @@
+package pipeline
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strings"
+
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+)
+
+const (
+	// ActionMask replaces a match with a [REDACTED:<detector>] marker.
+	ActionMask = "mask"
+	// ActionHash replaces a match with a keyed hash, so equal values can
+	// still be correlated.
+	ActionHash = "hash"
+	// ActionDropField removes a field whose value contains a match. Matches
+	// in the message are masked instead.
+	ActionDropField = "drop_field"
+)
+
+// RedactionConfig selects the detectors applied to messages and fields.
+type RedactionConfig struct {
+	// Disabled turns redaction off, e.g. for a single project.
+	Disabled bool `json:"disabled,omitempty"`
+	// Detectors lists built-in detectors by name; empty selects the
+	// default set, which excludes ipv4 and ipv6.
+	Detectors []string `json:"detectors,omitempty"`
+	// Custom adds detectors from regular expressions.
+	Custom []CustomDetector `json:"custom,omitempty"`
+	// Action is the default action (default: mask).
+	Action string `json:"action,omitempty"`
+	// Actions overrides the action per detector name.
+	Actions map[string]string `json:"actions,omitempty"`
+	// HashKey keys the HMAC used by the hash action, which requires it.
+	HashKey string `json:"hash_key,omitempty"`
+}
+
+// CustomDetector is a named regular expression. When it has a group named
+// secret, only that group is redacted.
+type CustomDetector struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+}
+
+type detector struct {
+	name string
+	re   *regexp.Regexp
+	// valid filters candidate matches, e.g. an IPv6 parse.
+	valid func(string) bool
+	// find narrows a candidate match to the part to redact, if any.
+	find func(string) (start, end int, ok bool)
+}
+
+// builtinDetectors are applied in order; the secret group, when present,
+// is the part that is redacted.
+var builtinDetectors = []detector{
+	{name: "private_key", re: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+	{name: "jwt", re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)},
+	{name: "bearer_token", re: regexp.MustCompile(`(?i)\bbearer\s+(?P<secret>[A-Za-z0-9\-._~+/]{8,}=*)`)},
+	{name: "aws_access_key", re: regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[A-Z0-9]{16}\b`)},
+	{name: "aws_secret_key", re: regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?(?P<secret>[A-Za-z0-9/+]{40})`)},
+	{name: "gcp_api_key", re: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{name: "azure_storage_key", re: regexp.MustCompile(`(?i)AccountKey=(?P<secret>[A-Za-z0-9+/]{86}==)`)},
+	{name: "github_token", re: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36}|github_pat_[A-Za-z0-9_]{82})\b`)},
+	{name: "slack_token", re: regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}`)},
+	{name: "stripe_key", re: regexp.MustCompile(`\b[rs]k_live_[A-Za-z0-9]{24,}\b`)},
+	{name: "email", re: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)},
+	{name: "credit_card", re: regexp.MustCompile(`\b(?:\d[ -]?){12,}\d\b`), find: cardNumber},
+	{name: "ipv4", re: regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1?\d?\d)\.){3}(?:25[0-5]|2[0-4]\d|1?\d?\d)\b`)},
+	{name: "ipv6", re: regexp.MustCompile(`(?i)(?:[0-9a-f]{0,4}:){2,7}[0-9a-f]{0,4}(?:%[0-9a-z]+)?`), valid: validIPv6},
+}
+
+// optInDetectors are only applied when listed explicitly.
+var optInDetectors = map[string]bool{"ipv4": true, "ipv6": true}
+
+// redactor applies a project's detectors to an entry.
+type redactor struct {
+	detectors []detector
+	actions   map[string]string
+	hashKey   []byte
+}
+
+func newRedactor(cfg *RedactionConfig) (*redactor, error) {
+	if cfg == nil || cfg.Disabled {
+		return nil, nil
+	}
+
+	defaultAction := cfg.Action
+	if defaultAction == "" {
+		defaultAction = ActionMask
+	}
+
+	r := &redactor{actions: make(map[string]string), hashKey: []byte(cfg.HashKey)}
+
+	selected := make(map[string]bool, len(cfg.Detectors))
+	for _, name := range cfg.Detectors {
+		selected[name] = true
+	}
+	known := make(map[string]bool, len(builtinDetectors))
+	for _, d := range builtinDetectors {
+		known[d.name] = true
+		if selected[d.name] || (len(selected) == 0 && !optInDetectors[d.name]) {
+			r.detectors = append(r.detectors, d)
+		}
+	}
+	for name := range selected {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown redaction detector %q", name)
+		}
+	}
+
+	for _, custom := range cfg.Custom {
+		if custom.Name == "" {
+			return nil, fmt.Errorf("custom redaction detector requires a name")
+		}
+		re, err := regexp.Compile(custom.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("custom redaction detector %s: %w", custom.Name, err)
+		}
+		r.detectors = append(r.detectors, detector{name: custom.Name, re: re})
+	}
+
+	for _, d := range r.detectors {
+		action := defaultAction
+		if override, ok := cfg.Actions[d.name]; ok {
+			action = override
+		}
+		switch action {
+		case ActionMask, ActionDropField:
+		case ActionHash:
+			// Without a key, the HMAC of a guessable value can be recomputed
+			if cfg.HashKey == "" {
+				return nil, fmt.Errorf("redaction action %q for %s requires hash_key", action, d.name)
+			}
+		default:
+			return nil, fmt.Errorf("unknown redaction action %q for %s", action, d.name)
+		}
+		r.actions[d.name] = action
+	}
+
+	return r, nil
+}
+
+// redact applies the detectors to the message and, recursively, to all
+// string field values.
+func (r *redactor) redact(entry *storage.LogEntry) {
+	entry.Message, _ = r.redactString(entry.Message, false)
+	if entry.Fields != nil {
+		r.redactMap(entry.Fields)
+	}
+}
+
+func (r *redactor) redactMap(fields map[string]interface{}) {
+	for key, value := range fields {
+		redacted, drop := r.redactValue(value)
+		if drop {
+			delete(fields, key)
+		} else {
+			fields[key] = redacted
+		}
+	}
+}
+
+func (r *redactor) redactValue(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case string:
+		return r.redactString(v, true)
+	case json.Number:
+		redacted, drop := r.redactString(string(v), true)
+		if redacted == string(v) {
+			return v, drop
+		}
+		return redacted, drop
+	case map[string]interface{}:
+		r.redactMap(v)
+	case []interface{}:
+		for i := range v {
+			// Array elements cannot be dropped individually, so they are masked
+			redacted, drop := r.redactValue(v[i])
+			if drop {
+				redacted = "[REDACTED]"
+			}
+			v[i] = redacted
+		}
+	}
+	return value, false
+}
+
+// redactString replaces detector matches. When dropping is allowed and a
+// drop_field detector matches, it reports that the field should go.
+func (r *redactor) redactString(s string, canDrop bool) (string, bool) {
+	for _, d := range r.detectors {
+		matches := d.re.FindAllStringSubmatchIndex(s, -1)
+		if len(matches) == 0 {
+			continue
+		}
+
+		secret := d.re.SubexpIndex("secret")
+		action := r.actions[d.name]
+
+		var b strings.Builder
+		last := 0
+		for _, m := range matches {
+			start, end := m[0], m[1]
+			if secret > 0 && m[2*secret] >= 0 {
+				start, end = m[2*secret], m[2*secret+1]
+			}
+			if d.valid != nil && !d.valid(s[start:end]) {
+				continue
+			}
+			if d.find != nil {
+				from, to, ok := d.find(s[start:end])
+				if !ok {
+					continue
+				}
+				start, end = start+from, start+to
+			}
+			if action == ActionDropField && canDrop {
+				return "", true
+			}
+
+			b.WriteString(s[last:start])
+			b.WriteString(r.replacement(d.name, action, s[start:end]))
+			last = end
+		}
+		if last > 0 {
+			b.WriteString(s[last:])
+			s = b.String()
+		}
+	}
+	return s, false
+}
+
+func (r *redactor) replacement(name, action, value string) string {
+	if action != ActionHash {
+		return "[REDACTED:" + name + "]"
+	}
+
+	mac := hmac.New(sha256.New, r.hashKey)
+	mac.Write([]byte(value))
+	return "[" + name + ":" + hex.EncodeToString(mac.Sum(nil))[:16] + "]"
+}
+
+// cardNumber finds a card number within a run of digit groups, which may
+// also hold neighbouring numbers such as an expiry date. A card number
+// starts and ends at group boundaries; the longest one passing the Luhn
+// check at the leftmost start is taken.
+func cardNumber(candidate string) (int, int, bool) {
+	var digits []int
+	for i := 0; i < len(candidate); i++ {
+		if candidate[i] >= '0' && candidate[i] <= '9' {
+			digits = append(digits, i)
+		}
+	}
+
+	for first := range digits {
+		if first > 0 && digits[first-1] == digits[first]-1 {
+			continue
+		}
+		for last := min(first+18, len(digits)-1); last >= first+12; last-- {
+			end := digits[last] + 1
+			if last+1 < len(digits) && digits[last+1] == end {
+				continue
+			}
+			if luhnValid(candidate[digits[first]:end]) {
+				return digits[first], end, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// luhnValid checks card numbers of 13 to 19 digits, ignoring separators.
+func luhnValid(candidate string) bool {
+	var digits []int
+	for _, c := range candidate {
+		if c >= '0' && c <= '9' {
+			digits = append(digits, int(c-'0'))
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	sum := 0
+	for i := range digits {
+		d := digits[len(digits)-1-i]
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
+
+func validIPv6(candidate string) bool {
+	addr, err := netip.ParseAddr(candidate)
+	return err == nil && addr.Is6()
+}
@@ -0,0 +1,217 @@
+package pipeline
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/bizjs/Lograil/ingestion/internal/parser"
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+)
+
+const (
+	// RuleDrop discards matching entries.
+	RuleDrop = "drop"
+	// RuleSample keeps a fraction of matching entries.
+	RuleSample = "sample"
+
+	// SampleRateField records the rate an entry was kept at, so counts can
+	// be re-weighted by 1/sample_rate at query time.
+	SampleRateField = "sample_rate"
+)
+
+// RuleConfig drops or samples the entries it matches. All conditions that
+// are set must hold; a rule without conditions matches every entry.
+type RuleConfig struct {
+	Name string `json:"name,omitempty"`
+	// Levels matches the entry level, ignoring case and spelling, so warn
+	// also matches WARNING.
+	Levels []string `json:"levels,omitempty"`
+	// Sources are glob patterns, as in path.Match.
+	Sources []string `json:"sources,omitempty"`
+	// Message is a regular expression matched against the message.
+	Message string `json:"message,omitempty"`
+	// Fields maps field names to regular expressions matched against their
+	// values. Nested fields use dotted names.
+	Fields map[string]string `json:"fields,omitempty"`
+
+	// Action is drop or sample.
+	Action string `json:"action"`
+	// Rate is the fraction of entries a sample rule keeps, from 0 to 1.
+	Rate float64 `json:"rate,omitempty"`
+	// Key names a field, e.g. trace_id, whose value decides whether an
+	// entry is kept, so related entries are kept or dropped together.
+	Key string `json:"key,omitempty"`
+}
+
+type rule struct {
+	name    string
+	levels  []string
+	sources []string
+	message *regexp.Regexp
+	fields  map[string]*regexp.Regexp
+
+	action string
+	rate   float64
+	key    string
+}
+
+func newRules(configs []RuleConfig) ([]rule, error) {
+	rules := make([]rule, 0, len(configs))
+	for i, cfg := range configs {
+		name := cfg.Name
+		if name == "" {
+			name = fmt.Sprintf("rule %d", i+1)
+		}
+
+		r := rule{name: name, sources: cfg.Sources, action: cfg.Action, rate: cfg.Rate, key: cfg.Key}
+		for _, level := range cfg.Levels {
+			canonical, _ := parser.NormalizeLevel(level, "")
+			r.levels = append(r.levels, canonical)
+		}
+		switch cfg.Action {
+		case RuleDrop:
+		case RuleSample:
+			if cfg.Rate < 0 || cfg.Rate > 1 || math.IsNaN(cfg.Rate) {
+				return nil, fmt.Errorf("%s: sample rate must be between 0 and 1", name)
+			}
+		default:
+			return nil, fmt.Errorf("%s: unknown action %q", name, cfg.Action)
+		}
+
+		for _, source := range cfg.Sources {
+			if _, err := path.Match(source, ""); err != nil {
+				return nil, fmt.Errorf("%s: invalid source pattern %q: %w", name, source, err)
+			}
+		}
+
+		if cfg.Message != "" {
+			re, err := regexp.Compile(cfg.Message)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid message pattern: %w", name, err)
+			}
+			r.message = re
+		}
+
+		if len(cfg.Fields) > 0 {
+			r.fields = make(map[string]*regexp.Regexp, len(cfg.Fields))
+			for field, pattern := range cfg.Fields {
+				re, err := regexp.Compile(pattern)
+				if err != nil {
+					return nil, fmt.Errorf("%s: invalid pattern for field %s: %w", name, field, err)
+				}
+				r.fields[field] = re
+			}
+		}
+
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+func (r *rule) matches(entry *storage.LogEntry) bool {
+	if len(r.levels) > 0 {
+		canonical, _ := parser.NormalizeLevel(entry.Level, "")
+		matched := false
+		for _, level := range r.levels {
+			if strings.EqualFold(level, entry.Level) || level == canonical {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if len(r.sources) > 0 {
+		matched := false
+		for _, pattern := range r.sources {
+			if ok, _ := path.Match(pattern, entry.Source); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	if r.message != nil && !r.message.MatchString(entry.Message) {
+		return false
+	}
+
+	for field, re := range r.fields {
+		value, ok := lookupField(entry.Fields, field)
+		if !ok || !re.MatchString(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// keep decides whether a matching entry is kept. Entries with the key
+// field are kept by the hash of its value; others are kept at random.
+func (r *rule) keep(entry *storage.LogEntry, random func() float64) bool {
+	switch {
+	case r.action == RuleDrop || r.rate <= 0:
+		return false
+	case r.rate >= 1:
+		return true
+	}
+
+	if r.key != "" {
+		if value, ok := lookupField(entry.Fields, r.key); ok {
+			h := fnv.New64a()
+			h.Write([]byte(value))
+			return float64(h.Sum64()>>11)/(1<<53) < r.rate
+		}
+	}
+	return random() < r.rate
+}
+
+// applyRules reports whether the entry is kept by the first matching rule.
+// Sampled entries that are kept record the sample rate.
+func applyRules(rules []rule, entry *storage.LogEntry, random func() float64) bool {
+	for i := range rules {
+		r := &rules[i]
+		if !r.matches(entry) {
+			continue
+		}
+		if !r.keep(entry, random) {
+			return false
+		}
+		if r.action == RuleSample {
+			if entry.Fields == nil {
+				entry.Fields = make(map[string]interface{}, 1)
+			}
+			entry.Fields[SampleRateField] = r.rate
+		}
+		return true
+	}
+	return true
+}
+
+// lookupField returns a field value as a string. Dotted names descend
+// into nested objects when there is no field with the full name.
+func lookupField(fields map[string]interface{}, name string) (string, bool) {
+	for fields != nil {
+		if value, ok := fields[name]; ok {
+			if value == nil {
+				return "", false
+			}
+			return fmt.Sprint(value), true
+		}
+
+		head, rest, found := strings.Cut(name, ".")
+		if !found {
+			return "", false
+		}
+		fields, _ = fields[head].(map[string]interface{})
+		name = rest
+	}
+	return "", false
+}
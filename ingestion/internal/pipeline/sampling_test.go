@@ -0,0 +1,94 @@
+package pipeline
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+)
+
+func TestRulesDropAndSample(t *testing.T) {
+	p, err := New(&Config{
+		Rules: []RuleConfig{
+			{Name: "debug", Levels: []string{"debug"}, Action: RuleDrop},
+		},
+		Projects: map[string]ProjectConfig{
+			"2": {Rules: []RuleConfig{
+				{Name: "health", Message: "^GET /health", Action: RuleDrop},
+				{Name: "static", Fields: map[string]string{"http.path": `^/static/`}, Action: RuleDrop},
+				{Name: "traces", Sources: []string{"api-*"}, Action: RuleSample, Rate: 0.25, Key: "trace_id"},
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	p.random = func() float64 { return 0.5 }
+
+	logs := []storage.LogEntry{
+		{ProjectID: 1, Level: "DEBUG", Message: "dropped by the default rule"},
+		{ProjectID: 1, Level: "info", Message: "kept"},
+		{ProjectID: 2, Level: "debug", Message: "project rules replace the default"},
+		{ProjectID: 2, Level: "info", Message: "GET /health 200"},
+		{ProjectID: 2, Level: "info", Message: "asset", Fields: map[string]interface{}{"http": map[string]interface{}{"path": "/static/app.js"}}},
+		{ProjectID: 2, Level: "info", Source: "api-public", Message: "no trace ID, sampled at random"},
+	}
+	kept := p.Process(logs)
+
+	var messages []string
+	for _, entry := range kept {
+		messages = append(messages, entry.Message)
+	}
+	want := []string{"kept", "project rules replace the default"}
+	if fmt.Sprint(messages) != fmt.Sprint(want) {
+		t.Errorf("Kept %q, want %q", messages, want)
+	}
+	if got := p.Stats().Dropped; got != 4 {
+		t.Errorf("Expected 4 dropped entries, got %d", got)
+	}
+}
+
+func TestSamplingByKeyKeepsTracesTogether(t *testing.T) {
+	p, err := New(&Config{Rules: []RuleConfig{{Action: RuleSample, Rate: 0.5, Key: "trace_id"}}})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	keptTraces := 0
+	for trace := 0; trace < 1000; trace++ {
+		var logs []storage.LogEntry
+		for line := 0; line < 3; line++ {
+			logs = append(logs, storage.LogEntry{Message: "step", Fields: map[string]interface{}{"trace_id": fmt.Sprintf("trace-%d", trace)}})
+		}
+
+		kept := p.Process(logs)
+		switch len(kept) {
+		case 0:
+		case 3:
+			keptTraces++
+			if kept[0].Fields[SampleRateField] != 0.5 {
+				t.Fatalf("Expected sample rate on kept entry, got %v", kept[0].Fields)
+			}
+		default:
+			t.Fatalf("Trace %d was split: kept %d of 3 entries", trace, len(kept))
+		}
+	}
+
+	if keptTraces < 400 || keptTraces > 600 {
+		t.Errorf("Expected about half of the traces to be kept, got %d", keptTraces)
+	}
+}
+
+func TestRulesConfigErrors(t *testing.T) {
+	configs := []RuleConfig{
+		{Action: "keep"},
+		{Action: RuleSample, Rate: 1.5},
+		{Action: RuleDrop, Message: "("},
+		{Action: RuleDrop, Sources: []string{"["}},
+	}
+	for _, cfg := range configs {
+		if _, err := newRules([]RuleConfig{cfg}); err == nil {
+			t.Errorf("Expected error for %+v", cfg)
+		}
+	}
+}
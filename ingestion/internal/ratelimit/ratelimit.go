@@ -0,0 +1,251 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// ScopeKey limits traffic per API key.
+	ScopeKey = "key"
+	// ScopeProject limits traffic per project, across all of its keys.
+	ScopeProject = "project"
+
+	// DimensionEvents counts log entries.
+	DimensionEvents = "events"
+	// DimensionBytes counts request body bytes.
+	DimensionBytes = "bytes"
+)
+
+// sweepInterval is how often idle, full buckets are evicted.
+const sweepInterval = time.Minute
+
+// Limit is a sustained rate per second with a burst allowance. A zero Rate
+// is unlimited.
+type Limit struct {
+	Rate  float64
+	Burst float64
+}
+
+// Options configures the limits applied to each key and each project.
+type Options struct {
+	KeyEvents     Limit
+	KeyBytes      Limit
+	ProjectEvents Limit
+	ProjectBytes  Limit
+}
+
+// Enabled reports whether any limit is configured.
+func (o Options) Enabled() bool {
+	return o.KeyEvents.Rate > 0 || o.KeyBytes.Rate > 0 || o.ProjectEvents.Rate > 0 || o.ProjectBytes.Rate > 0
+}
+
+// Request is traffic to be admitted. A zero KeyID skips the key limits.
+type Request struct {
+	KeyID     int
+	ProjectID int
+	Events    int
+	Bytes     int
+}
+
+// Decision describes the most constraining bucket for a request: the one
+// that rejected it, or the one with the least capacity left.
+type Decision struct {
+	Allowed   bool
+	Scope     string
+	Dimension string
+	// Limit is the sustained rate per second.
+	Limit float64
+	// Remaining is the capacity left after the request.
+	Remaining float64
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until a rejected request would be admitted.
+	RetryAfter time.Duration
+}
+
+// Error is returned for requests over a rate limit.
+type Error struct {
+	Decision Decision
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("rate limit exceeded for %s %s", e.Decision.Scope, e.Decision.Dimension)
+}
+
+// Counters tracks throttled traffic.
+type Counters struct {
+	Requests int64 `json:"requests"`
+	Events   int64 `json:"events"`
+	Bytes    int64 `json:"bytes"`
+}
+
+// Limiter enforces token-bucket limits per key and per project. A request
+// larger than the burst is admitted when its bucket is full and leaves the
+// bucket in debt, so large batches are slowed down rather than rejected
+// forever.
+type Limiter struct {
+	opts Options
+	now  func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[bucketKey]*bucket
+	throttled map[string]*Counters
+	lastSweep time.Time
+}
+
+type bucketKey struct {
+	scope     string
+	dimension string
+	id        int
+}
+
+type bucket struct {
+	limit  Limit
+	tokens float64
+	last   time.Time
+}
+
+func New(opts Options) *Limiter {
+	for _, limit := range []*Limit{&opts.KeyEvents, &opts.KeyBytes, &opts.ProjectEvents, &opts.ProjectBytes} {
+		if limit.Burst < limit.Rate {
+			limit.Burst = limit.Rate
+		}
+	}
+
+	return &Limiter{
+		opts:    opts,
+		now:     time.Now,
+		buckets: make(map[bucketKey]*bucket),
+		throttled: map[string]*Counters{
+			ScopeKey:     {},
+			ScopeProject: {},
+		},
+	}
+}
+
+// Allow admits the request if every applicable bucket has capacity for it,
+// taking the capacity from all of them; otherwise nothing is taken.
+func (l *Limiter) Allow(req Request) Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) > sweepInterval {
+		l.sweep(now)
+	}
+
+	type check struct {
+		bucket *bucket
+		cost   float64
+		key    bucketKey
+	}
+	var checks []check
+	add := func(scope, dimension string, id int, limit Limit, cost int) {
+		if limit.Rate <= 0 {
+			return
+		}
+		key := bucketKey{scope: scope, dimension: dimension, id: id}
+		b, ok := l.buckets[key]
+		if !ok {
+			b = &bucket{limit: limit, tokens: limit.Burst, last: now}
+			l.buckets[key] = b
+		}
+		b.refill(now)
+		checks = append(checks, check{bucket: b, cost: float64(cost), key: key})
+	}
+
+	if req.KeyID != 0 {
+		add(ScopeKey, DimensionEvents, req.KeyID, l.opts.KeyEvents, req.Events)
+		add(ScopeKey, DimensionBytes, req.KeyID, l.opts.KeyBytes, req.Bytes)
+	}
+	add(ScopeProject, DimensionEvents, req.ProjectID, l.opts.ProjectEvents, req.Events)
+	add(ScopeProject, DimensionBytes, req.ProjectID, l.opts.ProjectBytes, req.Bytes)
+
+	if len(checks) == 0 {
+		return Decision{Allowed: true}
+	}
+
+	for _, c := range checks {
+		if !c.bucket.admits(c.cost) {
+			counters := l.throttled[c.key.scope]
+			counters.Requests++
+			counters.Events += int64(req.Events)
+			counters.Bytes += int64(req.Bytes)
+
+			decision := c.bucket.decision(c.key, false)
+			decision.RetryAfter = c.bucket.retryAfter(c.cost)
+			return decision
+		}
+	}
+
+	var tightest Decision
+	ratio := math.Inf(1)
+	for _, c := range checks {
+		c.bucket.tokens -= c.cost
+		if r := c.bucket.tokens / c.bucket.limit.Burst; r < ratio {
+			ratio = r
+			tightest = c.bucket.decision(c.key, true)
+		}
+	}
+	return tightest
+}
+
+// Throttled returns the throttled traffic per scope.
+func (l *Limiter) Throttled() map[string]Counters {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	stats := make(map[string]Counters, len(l.throttled))
+	for scope, counters := range l.throttled {
+		stats[scope] = *counters
+	}
+	return stats
+}
+
+// sweep evicts buckets that have refilled completely, since they behave
+// the same as new ones.
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		b.refill(now)
+		if b.tokens >= b.limit.Burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(b.limit.Burst, b.tokens+elapsed*b.limit.Rate)
+		b.last = now
+	}
+}
+
+func (b *bucket) admits(cost float64) bool {
+	return b.tokens >= cost || (cost > 0 && b.tokens >= b.limit.Burst)
+}
+
+// retryAfter is the wait until the bucket admits cost, or is full for
+// requests larger than the burst.
+func (b *bucket) retryAfter(cost float64) time.Duration {
+	needed := math.Min(cost, b.limit.Burst) - b.tokens
+	if needed <= 0 {
+		return 0
+	}
+	return time.Duration(needed / b.limit.Rate * float64(time.Second))
+}
+
+func (b *bucket) decision(key bucketKey, allowed bool) Decision {
+	return Decision{
+		Allowed:   allowed,
+		Scope:     key.scope,
+		Dimension: key.dimension,
+		Limit:     b.limit.Rate,
+		Remaining: math.Max(0, b.tokens),
+		Reset:     time.Duration((b.limit.Burst - b.tokens) / b.limit.Rate * float64(time.Second)),
+	}
+}
@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestLimiter(opts Options) (*Limiter, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	limiter := New(opts)
+	limiter.now = func() time.Time { return now }
+	return limiter, &now
+}
+
+func TestLimiterEventsPerProject(t *testing.T) {
+	limiter, now := newTestLimiter(Options{ProjectEvents: Limit{Rate: 10, Burst: 20}})
+
+	if d := limiter.Allow(Request{ProjectID: 1, Events: 15}); !d.Allowed || d.Remaining != 5 {
+		t.Fatalf("Expected first request to pass with 5 remaining, got %+v", d)
+	}
+
+	d := limiter.Allow(Request{ProjectID: 1, Events: 10})
+	if d.Allowed || d.Scope != ScopeProject || d.Dimension != DimensionEvents {
+		t.Fatalf("Expected project events rejection, got %+v", d)
+	}
+	if d.RetryAfter != 500*time.Millisecond {
+		t.Errorf("Expected 500ms retry, got %s", d.RetryAfter)
+	}
+
+	// Other projects have their own bucket
+	if d := limiter.Allow(Request{ProjectID: 2, Events: 10}); !d.Allowed {
+		t.Errorf("Expected another project to pass, got %+v", d)
+	}
+
+	*now = now.Add(time.Second)
+	if d := limiter.Allow(Request{ProjectID: 1, Events: 10}); !d.Allowed {
+		t.Errorf("Expected request to pass after refill, got %+v", d)
+	}
+
+	throttled := limiter.Throttled()[ScopeProject]
+	if throttled.Requests != 1 || throttled.Events != 10 {
+		t.Errorf("Unexpected throttled counters: %+v", throttled)
+	}
+}
+
+func TestLimiterTakesFromAllBuckets(t *testing.T) {
+	limiter, _ := newTestLimiter(Options{
+		KeyEvents:    Limit{Rate: 100},
+		ProjectBytes: Limit{Rate: 1000},
+	})
+
+	if d := limiter.Allow(Request{KeyID: 7, ProjectID: 1, Events: 1, Bytes: 800}); !d.Allowed {
+		t.Fatalf("Expected request to pass, got %+v", d)
+	}
+	if d := limiter.Allow(Request{KeyID: 7, ProjectID: 1, Events: 1, Bytes: 800}); d.Allowed || d.Dimension != DimensionBytes {
+		t.Fatalf("Expected bytes rejection, got %+v", d)
+	}
+
+	// The rejected request took nothing from the key bucket
+	if d := limiter.Allow(Request{KeyID: 7, ProjectID: 1, Events: 99}); !d.Allowed {
+		t.Errorf("Expected key bucket to be untouched by the rejection, got %+v", d)
+	}
+}
+
+func TestLimiterLargeRequestGoesIntoDebt(t *testing.T) {
+	limiter, now := newTestLimiter(Options{ProjectEvents: Limit{Rate: 10}})
+
+	if d := limiter.Allow(Request{Events: 25}); !d.Allowed {
+		t.Fatalf("Expected oversized request to pass on a full bucket, got %+v", d)
+	}
+	d := limiter.Allow(Request{})
+	if d.Allowed {
+		t.Fatal("Expected requests to be rejected while in debt")
+	}
+	if d.RetryAfter != 1500*time.Millisecond {
+		t.Errorf("Expected 1.5s retry, got %s", d.RetryAfter)
+	}
+
+	*now = now.Add(1500 * time.Millisecond)
+	if d := limiter.Allow(Request{}); !d.Allowed {
+		t.Errorf("Expected debt to be repaid, got %+v", d)
+	}
+}
@@ -0,0 +1,253 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/bizjs/Lograil/ingestion/internal/buffer"
+	"github.com/bizjs/Lograil/ingestion/internal/spool"
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+)
+
+// Policy selects what happens to entries a sink cannot take.
+type Policy string
+
+const (
+	// PolicySpool spools failed batches to disk and replays them once the
+	// sink is healthy. A full queue slows down ingestion instead of
+	// dropping entries.
+	PolicySpool Policy = "spool"
+	// PolicyDrop drops failed batches, and entries arriving while the
+	// queue is full, so that the sink never slows down ingestion.
+	PolicyDrop Policy = "drop"
+)
+
+// ParsePolicy validates a configured failure policy name.
+func ParsePolicy(value string) (Policy, error) {
+	switch Policy(value) {
+	case PolicySpool, PolicyDrop:
+		return Policy(value), nil
+	default:
+		return "", fmt.Errorf("unknown sink failure policy %q", value)
+	}
+}
+
+// Config configures one sink of a Fanout.
+type Config struct {
+	Name string
+	Sink storage.Sink
+
+	// Buffer controls how entries are batched for the sink.
+	Buffer buffer.Options
+
+	OnFailure Policy
+	// Spool configures the disk spool used with PolicySpool.
+	Spool spool.Options
+
+	// OnWrite, if set, is called after each write to the sink, including
+	// spool replays, with the number of entries, the elapsed time and the
+	// error. Writes refused by an open circuit breaker are not reported.
+	OnWrite func(entries int, elapsed time.Duration, err error)
+}
+
+// Status is the health of one sink.
+type Status struct {
+	Name      string `json:"name"`
+	OnFailure Policy `json:"on_failure"`
+	Healthy   bool   `json:"healthy"`
+	Error     string `json:"error,omitempty"`
+	Pending   int    `json:"pending"`
+	Capacity  int    `json:"capacity"`
+	// Failed counts batches the sink failed to write.
+	Failed int64 `json:"failed"`
+	// Dropped counts entries lost to failed writes or a full queue.
+	Dropped        int64        `json:"dropped"`
+	Spool          *SpoolStatus `json:"spool,omitempty"`
+	CircuitBreaker string       `json:"circuit_breaker,omitempty"`
+}
+
+// SpoolStatus is the size of a sink's disk spool.
+type SpoolStatus struct {
+	Segments int   `json:"segments"`
+	Bytes    int64 `json:"bytes"`
+}
+
+// Fanout writes every batch to each of its sinks. Each sink has its own
+// queue, batching and failure policy, so a slow or failing sink does not
+// hold up the others beyond what its policy allows.
+type Fanout struct {
+	sinks   []*sink
+	closing chan struct{}
+}
+
+type sink struct {
+	name    string
+	storage storage.Sink
+	policy  Policy
+	buffer  *buffer.Buffer
+	spool   *spool.Spool
+	onWrite func(entries int, elapsed time.Duration, err error)
+
+	failed  atomic.Int64
+	dropped atomic.Int64
+}
+
+// New opens the spools of sinks with PolicySpool and starts flushing.
+func New(configs []Config) (*Fanout, error) {
+	if len(configs) == 0 {
+		return nil, errors.New("no sinks configured")
+	}
+
+	f := &Fanout{closing: make(chan struct{})}
+	for _, cfg := range configs {
+		s := &sink{name: cfg.Name, storage: cfg.Sink, policy: cfg.OnFailure, onWrite: cfg.OnWrite}
+
+		if cfg.OnFailure == PolicySpool {
+			sp, err := spool.Open(cfg.Spool, s.store, cfg.Sink.Health)
+			if err != nil {
+				f.Close(context.Background())
+				return nil, fmt.Errorf("sink %s: %w", cfg.Name, err)
+			}
+			s.spool = sp
+		}
+
+		s.buffer = buffer.New(cfg.Buffer, s.write)
+		f.sinks = append(f.sinks, s)
+	}
+	return f, nil
+}
+
+// Write queues logs for every sink. It only blocks while the queue of a
+// sink with PolicySpool is full.
+func (f *Fanout) Write(logs []storage.LogEntry) error {
+	var errs []error
+	for _, s := range f.sinks {
+		if err := f.enqueue(s, logs); err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", s.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// enqueue hands logs to the sink's queue in chunks it can hold.
+func (f *Fanout) enqueue(s *sink, logs []storage.LogEntry) error {
+	for len(logs) > 0 {
+		n := min(len(logs), s.buffer.Capacity())
+		chunk := logs[:n]
+		logs = logs[n:]
+
+		for {
+			err := s.buffer.Enqueue(chunk)
+			if err == nil {
+				break
+			}
+			if s.policy == PolicyDrop || !errors.Is(err, buffer.ErrFull) {
+				s.dropped.Add(int64(len(chunk) + len(logs)))
+				return err
+			}
+
+			select {
+			case <-f.closing:
+				s.dropped.Add(int64(len(chunk) + len(logs)))
+				return err
+			case <-time.After(50 * time.Millisecond):
+			}
+		}
+	}
+	return nil
+}
+
+// write is the sink's flush function, applying its failure policy.
+func (s *sink) write(logs []storage.LogEntry) error {
+	var err error
+	if s.spool != nil {
+		err = s.spool.Write(logs)
+	} else {
+		err = s.store(logs)
+	}
+	if err != nil {
+		s.failed.Add(1)
+		s.dropped.Add(int64(len(storage.Unwritten(logs, err))))
+		return fmt.Errorf("sink %s: %w", s.name, err)
+	}
+	return nil
+}
+
+// store writes logs to the sink's storage and reports the write.
+func (s *sink) store(logs []storage.LogEntry) error {
+	start := time.Now()
+	err := s.storage.Write(logs)
+	if s.onWrite != nil && !errors.Is(err, storage.ErrCircuitOpen) {
+		s.onWrite(len(logs), time.Since(start), err)
+	}
+	return err
+}
+
+// Stats reports each sink's queue, failures and spool without checking
+// its health, which leaves Healthy and Error unset.
+func (f *Fanout) Stats() []Status {
+	statuses := make([]Status, 0, len(f.sinks))
+	for _, s := range f.sinks {
+		status := Status{
+			Name:      s.name,
+			OnFailure: s.policy,
+			Pending:   s.buffer.Len(),
+			Capacity:  s.buffer.Capacity(),
+			Failed:    s.failed.Load(),
+			Dropped:   s.dropped.Load(),
+		}
+		if s.spool != nil {
+			segments, bytes := s.spool.Stats()
+			status.Spool = &SpoolStatus{Segments: segments, Bytes: bytes}
+		}
+		if breaker, ok := s.storage.(interface{ BreakerState() string }); ok {
+			status.CircuitBreaker = breaker.BreakerState()
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// Status is Stats with the health of each sink checked. A sink whose
+// circuit breaker is open is unhealthy.
+func (f *Fanout) Status() []Status {
+	statuses := f.Stats()
+	for i, s := range f.sinks {
+		err := s.storage.Health()
+		if err == nil && statuses[i].CircuitBreaker == storage.BreakerOpen {
+			err = storage.ErrCircuitOpen
+		}
+		statuses[i].Healthy = err == nil
+		if err != nil {
+			statuses[i].Error = err.Error()
+		}
+	}
+	return statuses
+}
+
+// Close drains each sink's queue, then closes its spool and the sink.
+// Spooled batches remain on disk for the next start.
+func (f *Fanout) Close(ctx context.Context) error {
+	close(f.closing)
+
+	var errs []error
+	for _, s := range f.sinks {
+		if s.buffer != nil {
+			if err := s.buffer.Close(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("sink %s: %w", s.name, err))
+			}
+		}
+		if s.spool != nil {
+			if err := s.spool.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("sink %s: %w", s.name, err))
+			}
+		}
+		if err := s.storage.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", s.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,124 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bizjs/Lograil/ingestion/internal/buffer"
+	"github.com/bizjs/Lograil/ingestion/internal/spool"
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+)
+
+type stubSink struct {
+	mu      sync.Mutex
+	err     error
+	written int
+}
+
+func (s *stubSink) Write(logs []storage.LogEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.written += len(logs)
+	return nil
+}
+
+func (s *stubSink) Health() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *stubSink) Close() error { return nil }
+
+func (s *stubSink) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+func (s *stubSink) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.written
+}
+
+func entries(n int) []storage.LogEntry {
+	logs := make([]storage.LogEntry, n)
+	for i := range logs {
+		logs[i] = storage.LogEntry{Level: "info", Message: "m", Source: "test"}
+	}
+	return logs
+}
+
+func TestFanoutPolicies(t *testing.T) {
+	healthy := &stubSink{}
+	failing := &stubSink{err: errors.New("unavailable")}
+	spooled := &stubSink{err: errors.New("unavailable")}
+
+	// Writes are observed beneath the spool
+	var observed, failed atomic.Int64
+	onWrite := func(entries int, elapsed time.Duration, err error) {
+		observed.Add(int64(entries))
+		if err != nil {
+			failed.Add(1)
+		}
+	}
+
+	f, err := New([]Config{
+		{Name: "healthy", Sink: healthy, OnFailure: PolicyDrop, Buffer: buffer.Options{BatchSize: 2}},
+		{Name: "failing", Sink: failing, OnFailure: PolicyDrop, Buffer: buffer.Options{Capacity: 3}},
+		{Name: "spooled", Sink: spooled, OnFailure: PolicySpool, Spool: spool.Options{Dir: t.TempDir(), ReplayInterval: time.Hour}, OnWrite: onWrite},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	// The failing sink's queue only holds 3 entries
+	if err := f.Write(entries(5)); err == nil {
+		t.Error("Expected an error for entries dropped from a full queue")
+	}
+
+	stats := f.Stats()
+	if stats[1].Pending != 3 || stats[1].Dropped != 2 {
+		t.Errorf("Expected 3 pending and 2 dropped entries, got %+v", stats[1])
+	}
+
+	if err := f.Close(context.Background()); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	if healthy.count() != 5 {
+		t.Errorf("Expected 5 entries in the healthy sink, got %d", healthy.count())
+	}
+	stats = f.Stats()
+	if stats[1].Failed != 1 || stats[1].Dropped != 5 {
+		t.Errorf("Expected the failed batch to be dropped, got %+v", stats[1])
+	}
+	if stats[2].Dropped != 0 || stats[2].Spool == nil || stats[2].Spool.Segments != 1 {
+		t.Errorf("Expected the failed batch to be spooled, got %+v", stats[2])
+	}
+	if observed.Load() != 5 || failed.Load() != 1 {
+		t.Errorf("Expected one failed write of 5 entries to be observed, got %d entries and %d failures", observed.Load(), failed.Load())
+	}
+
+	status := f.Status()
+	if !status[0].Healthy || status[1].Healthy || status[1].Error != "unavailable" {
+		t.Errorf("Unexpected health: %+v", status)
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	if policy, err := ParsePolicy("spool"); err != nil || policy != PolicySpool {
+		t.Errorf("Expected PolicySpool, got %q %v", policy, err)
+	}
+	if _, err := ParsePolicy("retry"); err == nil {
+		t.Error("Expected an error for an unknown policy")
+	}
+}
@@ -0,0 +1,418 @@
+package spool
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+)
+
+const segmentExt = ".wal"
+
+// ErrFull is returned when appending would exceed the spool size cap.
+var ErrFull = errors.New("spool size limit reached")
+
+// Options configures a Spool.
+type Options struct {
+	// Dir is where segment files are stored.
+	Dir string
+	// MaxBytes caps the total size of all segments.
+	MaxBytes int64
+	// SegmentBytes is the size at which the active segment is rotated.
+	SegmentBytes int64
+	// ReplayInterval is how often the spool checks storage health and
+	// replays pending segments.
+	ReplayInterval time.Duration
+}
+
+// Spool is a segmented write-ahead log for batches that could not be
+// written to storage. Batches are replayed in the order they were spooled
+// once the health check succeeds again. Only the entries a failed write left
+// unwritten are retried. Delivery is still at-least-once: a segment
+// interrupted mid-replay is resent from its start after a restart.
+type Spool struct {
+	opts   Options
+	write  func([]storage.LogEntry) error
+	health func() error
+
+	mu       sync.Mutex
+	segments []segment // oldest first; the last one is active
+	active   *os.File
+	size     int64
+	nextID   uint64
+	replayAt int64 // offset already replayed in segments[0]
+	// replayRest holds the entries of the record at replayAt that a failed
+	// replay left unwritten
+	replayRest []storage.LogEntry
+
+	replayMu sync.Mutex
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+type segment struct {
+	id   uint64
+	path string
+	size int64
+}
+
+// Open loads any existing segments from opts.Dir and starts the replay loop.
+// write delivers batches to storage and health reports whether storage is
+// reachable.
+func Open(opts Options, write func([]storage.LogEntry) error, health func() error) (*Spool, error) {
+	if opts.SegmentBytes <= 0 {
+		opts.SegmentBytes = 64 << 20
+	}
+	if opts.ReplayInterval <= 0 {
+		opts.ReplayInterval = 5 * time.Second
+	}
+
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	s := &Spool{
+		opts:   opts,
+		write:  write,
+		health: health,
+		nextID: 1,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if len(s.segments) > 0 {
+		log.Printf("Spool has %d pending segments (%d bytes) to replay", len(s.segments), s.size)
+	}
+
+	go s.replayLoop()
+
+	return s, nil
+}
+
+// Write delivers a batch to storage, spooling it instead when storage fails
+// or when older spooled batches are still waiting, so ordering is kept.
+func (s *Spool) Write(logs []storage.LogEntry) error {
+	if s.Pending() {
+		return s.Append(logs)
+	}
+
+	if err := s.write(logs); err != nil {
+		// Retrying a rejected payload would block replay forever
+		if storage.IsPermanent(err) {
+			return err
+		}
+		logs = storage.Unwritten(logs, err)
+		log.Printf("Storage write failed, spooling %d logs: %v", len(logs), err)
+		return s.Append(logs)
+	}
+
+	return nil
+}
+
+// Append persists a batch to the active segment.
+func (s *Spool) Append(logs []storage.LogEntry) error {
+	payload, err := json.Marshal(logs)
+	if err != nil {
+		return fmt.Errorf("failed to encode spooled batch: %w", err)
+	}
+
+	record := make([]byte, 8+len(payload))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(payload))
+	copy(record[8:], payload)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.opts.MaxBytes > 0 && s.size+int64(len(record)) > s.opts.MaxBytes {
+		return ErrFull
+	}
+
+	if s.active == nil || s.segments[len(s.segments)-1].size >= s.opts.SegmentBytes {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := s.active.Write(record); err != nil {
+		return fmt.Errorf("failed to write spool segment: %w", err)
+	}
+	if err := s.active.Sync(); err != nil {
+		return fmt.Errorf("failed to sync spool segment: %w", err)
+	}
+
+	s.segments[len(s.segments)-1].size += int64(len(record))
+	s.size += int64(len(record))
+
+	return nil
+}
+
+// Pending reports whether any batches are waiting to be replayed.
+func (s *Spool) Pending() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size > 0
+}
+
+// Stats returns the number of segments and total bytes on disk.
+func (s *Spool) Stats() (segments int, bytes int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.segments), s.size
+}
+
+// Close stops the replay loop and closes the active segment. Spooled
+// batches remain on disk and are replayed on the next Open.
+func (s *Spool) Close() error {
+	close(s.stop)
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active != nil {
+		err := s.active.Close()
+		s.active = nil
+		return err
+	}
+	return nil
+}
+
+func (s *Spool) load() error {
+	entries, err := os.ReadDir(s.opts.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to read spool directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return fmt.Errorf("failed to stat spool segment: %w", err)
+		}
+		if info.Size() == 0 {
+			os.Remove(filepath.Join(s.opts.Dir, name))
+			continue
+		}
+
+		s.segments = append(s.segments, segment{id: id, path: filepath.Join(s.opts.Dir, name), size: info.Size()})
+		s.size += info.Size()
+		if id >= s.nextID {
+			s.nextID = id + 1
+		}
+	}
+
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i].id < s.segments[j].id })
+
+	return nil
+}
+
+// rotate closes the active segment and opens a new one. Callers hold s.mu.
+func (s *Spool) rotate() error {
+	if s.active != nil {
+		if err := s.active.Close(); err != nil {
+			return fmt.Errorf("failed to close spool segment: %w", err)
+		}
+		s.active = nil
+	}
+
+	path := filepath.Join(s.opts.Dir, fmt.Sprintf("%020d%s", s.nextID, segmentExt))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open spool segment: %w", err)
+	}
+
+	s.active = file
+	s.segments = append(s.segments, segment{id: s.nextID, path: path})
+	s.nextID++
+
+	return nil
+}
+
+func (s *Spool) replayLoop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.opts.ReplayInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if !s.Pending() {
+				continue
+			}
+			if err := s.health(); err != nil {
+				continue
+			}
+			if err := s.Replay(); err != nil {
+				log.Printf("Spool replay paused: %v", err)
+			}
+		}
+	}
+}
+
+// Replay writes spooled batches to storage oldest first and removes each
+// segment once it has been fully delivered. It stops at the first failure.
+func (s *Spool) Replay() error {
+	s.replayMu.Lock()
+	defer s.replayMu.Unlock()
+
+	for {
+		seg, offset, ok := s.oldest()
+		if !ok {
+			return nil
+		}
+
+		if err := s.replaySegment(seg, offset); err != nil {
+			return err
+		}
+
+		if err := s.remove(seg); err != nil {
+			return err
+		}
+	}
+}
+
+// oldest returns the segment to replay next, rotating the active segment
+// first so that it is no longer appended to while being read.
+func (s *Spool) oldest() (segment, int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.segments) == 0 {
+		return segment{}, 0, false
+	}
+
+	if len(s.segments) == 1 && s.active != nil {
+		if s.segments[0].size == 0 {
+			return segment{}, 0, false
+		}
+		if err := s.rotate(); err != nil {
+			log.Printf("Failed to rotate spool segment: %v", err)
+			return segment{}, 0, false
+		}
+	}
+
+	return s.segments[0], s.replayAt, true
+}
+
+// rest returns the unwritten entries of the record at offset left by an
+// earlier failed replay, if any.
+func (s *Spool) rest(offset int64) ([]storage.LogEntry, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.replayRest, s.replayRest != nil && s.replayAt == offset
+}
+
+func (s *Spool) replaySegment(seg segment, offset int64) error {
+	file, err := os.Open(seg.path)
+	if err != nil {
+		return fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek spool segment: %w", err)
+	}
+
+	reader := bufio.NewReader(file)
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			log.Printf("Truncated spool segment %s at offset %d, skipping remainder", seg.path, offset)
+			return nil
+		}
+
+		// A length past the end of the segment is corrupt, and must not be
+		// allocated
+		length := int64(binary.BigEndian.Uint32(header[0:4]))
+		if length > seg.size-offset-int64(len(header)) {
+			log.Printf("Corrupt spool record length in %s at offset %d, skipping remainder", seg.path, offset)
+			return nil
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			log.Printf("Truncated spool segment %s at offset %d, skipping remainder", seg.path, offset)
+			return nil
+		}
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+			log.Printf("Corrupt spool record in %s at offset %d, skipping remainder", seg.path, offset)
+			return nil
+		}
+
+		logs, ok := s.rest(offset)
+		if !ok {
+			decoder := json.NewDecoder(bytes.NewReader(payload))
+			decoder.UseNumber()
+			if err := decoder.Decode(&logs); err != nil {
+				log.Printf("Undecodable spool record in %s at offset %d, skipping it: %v", seg.path, offset, err)
+				logs = nil
+			}
+		}
+		if len(logs) > 0 {
+			if err := s.write(logs); err != nil {
+				if !storage.IsPermanent(err) {
+					s.mu.Lock()
+					s.replayRest = storage.Unwritten(logs, err)
+					s.mu.Unlock()
+					return err
+				}
+				log.Printf("Dropping spooled batch of %d logs rejected by storage: %v", len(logs), err)
+			}
+		}
+
+		offset += int64(len(header) + len(payload))
+		s.mu.Lock()
+		s.replayAt = offset
+		s.replayRest = nil
+		s.mu.Unlock()
+	}
+}
+
+func (s *Spool) remove(seg segment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove spool segment: %w", err)
+	}
+
+	s.segments = s.segments[1:]
+	s.size -= seg.size
+	s.replayAt = 0
+	s.replayRest = nil
+
+	return nil
+}
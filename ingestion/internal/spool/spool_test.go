@@ -0,0 +1,154 @@
+package spool
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bizjs/Lograil/ingestion/internal/storage"
+)
+
+func TestSpoolReplaysInOrderAfterReopen(t *testing.T) {
+	dir := t.TempDir()
+	down := errors.New("connection refused")
+	healthy := func() error { return nil }
+
+	var written []string
+	failing := func(logs []storage.LogEntry) error { return down }
+	recording := func(logs []storage.LogEntry) error {
+		for _, l := range logs {
+			written = append(written, l.Message)
+		}
+		return nil
+	}
+
+	opts := Options{Dir: dir, SegmentBytes: 64, ReplayInterval: time.Hour}
+	s, err := Open(opts, failing, healthy)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	for _, msg := range []string{"one", "two", "three"} {
+		if err := s.Write([]storage.LogEntry{{Message: msg, Level: "info", Source: "test"}}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	if segments, _ := s.Stats(); segments < 2 {
+		t.Errorf("Expected segments to rotate, got %d", segments)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	s, err = Open(opts, recording, healthy)
+	if err != nil {
+		t.Fatalf("Reopen failed: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.Replay(); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+
+	if len(written) != 3 || written[0] != "one" || written[1] != "two" || written[2] != "three" {
+		t.Errorf("Unexpected replay order: %v", written)
+	}
+	if s.Pending() {
+		t.Error("Expected spool to be empty after replay")
+	}
+}
+
+func TestSpoolRejectsAppendOverLimit(t *testing.T) {
+	s, err := Open(Options{Dir: t.TempDir(), MaxBytes: 32, ReplayInterval: time.Hour}, nil, nil)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s.Close()
+
+	err = s.Append([]storage.LogEntry{{Message: "this entry does not fit in 32 bytes"}})
+	if !errors.Is(err, ErrFull) {
+		t.Errorf("Expected ErrFull, got %v", err)
+	}
+}
+
+func TestSpoolSkipsRecordWithCorruptLength(t *testing.T) {
+	dir := t.TempDir()
+
+	// A torn header claiming a 4 GiB record
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header[0:4], 0xFFFFFFFF)
+	if err := os.WriteFile(filepath.Join(dir, "00000000000000000001"+segmentExt), header, 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	var calls int
+	write := func(logs []storage.LogEntry) error {
+		calls++
+		return nil
+	}
+
+	s, err := Open(Options{Dir: dir, ReplayInterval: time.Hour}, write, func() error { return nil })
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.Replay(); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("Expected no writes from a corrupt segment, got %d", calls)
+	}
+	if s.Pending() {
+		t.Error("Expected corrupt segment to be removed")
+	}
+}
+
+func TestSpoolRetriesOnlyUnwrittenEntries(t *testing.T) {
+	down := errors.New("connection refused")
+	logs := []storage.LogEntry{
+		{Message: "a", ProjectID: 1},
+		{Message: "b", ProjectID: 2},
+	}
+
+	var written []string
+	failProject2 := true
+	write := func(batch []storage.LogEntry) error {
+		var remaining []storage.LogEntry
+		for _, l := range batch {
+			if l.ProjectID == 2 && failProject2 {
+				remaining = append(remaining, l)
+				continue
+			}
+			written = append(written, l.Message)
+		}
+		if len(remaining) > 0 {
+			return &storage.PartialWriteError{Remaining: remaining, Err: down}
+		}
+		return nil
+	}
+
+	s, err := Open(Options{Dir: t.TempDir(), ReplayInterval: time.Hour}, write, func() error { return nil })
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer s.Close()
+
+	if err := s.Write(logs); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := s.Replay(); err == nil {
+		t.Fatal("Expected replay to fail while project 2 is down")
+	}
+
+	failProject2 = false
+	if err := s.Replay(); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if len(written) != 2 || written[0] != "a" || written[1] != "b" {
+		t.Errorf("Expected each entry written once, got %v", written)
+	}
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrCircuitOpen is returned without contacting VictoriaLogs while the
+// circuit breaker is open.
+var ErrCircuitOpen = errors.New("VictoriaLogs circuit breaker is open")
+
+// StatusError is returned when VictoriaLogs answers with a non-200 status.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("VictoriaLogs returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// Temporary reports whether the request may succeed if retried.
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode >= 500 ||
+		e.StatusCode == http.StatusRequestTimeout ||
+		e.StatusCode == http.StatusTooManyRequests
+}
+
+// TransportError is returned when VictoriaLogs could not be reached.
+type TransportError struct {
+	Err error
+}
+
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("failed to send logs to VictoriaLogs: %v", e.Err)
+}
+
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// IsRetryable reports whether err is a transient failure: a connection
+// error, a 5xx, 408 or 429 response, or an open circuit breaker.
+func IsRetryable(err error) bool {
+	if errors.Is(err, ErrCircuitOpen) {
+		return true
+	}
+
+	var transportErr *TransportError
+	if errors.As(err, &transportErr) {
+		return true
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Temporary()
+	}
+
+	return false
+}
+
+// IsPermanent reports whether VictoriaLogs rejected the request in a way
+// that retrying cannot fix, such as a malformed payload.
+func IsPermanent(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && !statusErr.Temporary()
+}
+
+// PartialWriteError is returned when some projects of a batch were written
+// and others failed with a retryable error. Remaining holds the entries
+// that were not written, so that only they are retried.
+type PartialWriteError struct {
+	Remaining []LogEntry
+	Err       error
+}
+
+func (e *PartialWriteError) Error() string {
+	return fmt.Sprintf("%d logs not written: %v", len(e.Remaining), e.Err)
+}
+
+func (e *PartialWriteError) Unwrap() error {
+	return e.Err
+}
+
+// Unwritten returns the entries of logs that a failed write left unwritten:
+// the remainder of a PartialWriteError, or else all of logs.
+func Unwritten(logs []LogEntry, err error) []LogEntry {
+	var partialErr *PartialWriteError
+	if errors.As(err, &partialErr) {
+		return partialErr.Remaining
+	}
+	return logs
+}
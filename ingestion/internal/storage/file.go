@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	activeFileName  = "lograil.log"
+	rotatedPrefix   = "lograil-"
+	rotatedSuffix   = ".log"
+	rotatedTimeForm = "20060102T150405.000000000"
+)
+
+// FileOptions configures a FileSink.
+type FileOptions struct {
+	// Dir holds the active file and the rotated ones.
+	Dir string
+	// MaxBytes is the size at which the active file is rotated.
+	MaxBytes int64
+	// MaxFiles is the number of rotated files kept; the oldest are removed.
+	MaxFiles int
+}
+
+// FileSink writes entries as JSON lines to a local file, rotating it by
+// size. Rotated files are named after the time of rotation.
+type FileSink struct {
+	opts FileOptions
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	err  error
+	// rotated is the time of the last rotation, which names rotated files
+	rotated time.Time
+}
+
+func NewFileSink(opts FileOptions) (*FileSink, error) {
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = 100 << 20
+	}
+	if opts.MaxFiles <= 0 {
+		opts.MaxFiles = 10
+	}
+
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create file sink directory: %w", err)
+	}
+
+	s := &FileSink{opts: opts}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) Write(logs []LogEntry) error {
+	payload, err := encodeLines(logs)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = s.write(payload)
+	return s.err
+}
+
+func (s *FileSink) write(payload []byte) error {
+	if s.file == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+	if s.size > 0 && s.size+int64(len(payload)) > s.opts.MaxBytes {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.file.Write(payload)
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write log file: %w", err)
+	}
+	return nil
+}
+
+// Health returns the error of the last write, if it failed.
+func (s *FileSink) Health() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(filepath.Join(s.opts.Dir, activeFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+
+	s.file = file
+	s.size = info.Size()
+	return nil
+}
+
+// rotate renames the active file and opens a new one, then removes rotated
+// files beyond MaxFiles.
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	s.file = nil
+
+	// Names must not repeat, even for rotations within a clock tick
+	now := time.Now().UTC()
+	if !now.After(s.rotated) {
+		now = s.rotated.Add(time.Nanosecond)
+	}
+	s.rotated = now
+
+	rotated := rotatedPrefix + now.Format(rotatedTimeForm) + rotatedSuffix
+	if err := os.Rename(filepath.Join(s.opts.Dir, activeFileName), filepath.Join(s.opts.Dir, rotated)); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	if err := s.open(); err != nil {
+		return err
+	}
+
+	files, err := filepath.Glob(filepath.Join(s.opts.Dir, rotatedPrefix+"*"+rotatedSuffix))
+	if err != nil {
+		return err
+	}
+	// Timestamps sort lexically, so the oldest files come first
+	sort.Strings(files)
+	for len(files) > s.opts.MaxFiles {
+		if err := os.Remove(files[0]); err != nil {
+			return fmt.Errorf("failed to remove rotated log file: %w", err)
+		}
+		files = files[1:]
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileSinkRotates(t *testing.T) {
+	dir := t.TempDir()
+	sink, err := NewFileSink(FileOptions{Dir: dir, MaxBytes: 100, MaxFiles: 2})
+	if err != nil {
+		t.Fatalf("NewFileSink failed: %v", err)
+	}
+	defer sink.Close()
+
+	entry := LogEntry{Level: "info", Message: strings.Repeat("x", 40), Source: "test"}
+	for i := 0; i < 5; i++ {
+		if err := sink.Write([]LogEntry{entry}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	rotated, err := filepath.Glob(filepath.Join(dir, rotatedPrefix+"*"+rotatedSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rotated) != 2 {
+		t.Errorf("Expected MaxFiles rotated files, got %v", rotated)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, activeFileName))
+	if err != nil {
+		t.Fatalf("Failed to read active file: %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 1 || !strings.Contains(string(data), `"message":"xxx`) {
+		t.Errorf("Expected one JSON line in the active file, got %q", data)
+	}
+	if err := sink.Health(); err != nil {
+		t.Errorf("Expected a healthy sink, got %v", err)
+	}
+}
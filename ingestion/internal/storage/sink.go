@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Sink is a destination for log entries. Implementations must be safe for
+// concurrent use.
+type Sink interface {
+	// Write persists a batch of entries.
+	Write(logs []LogEntry) error
+	// Health reports whether the sink can currently accept writes.
+	Health() error
+	// Close releases the sink's resources once no more writes arrive.
+	Close() error
+}
+
+// WriterSink writes entries as JSON lines to an io.Writer, such as stdout.
+type WriterSink struct {
+	mu  sync.Mutex
+	w   io.Writer
+	err error
+}
+
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+func (s *WriterSink) Write(logs []LogEntry) error {
+	payload, err := encodeLines(logs)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, s.err = s.w.Write(payload)
+	return s.err
+}
+
+// Health returns the error of the last write, if it failed.
+func (s *WriterSink) Health() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *WriterSink) Close() error {
+	return nil
+}
+
+// encodeLines encodes logs as JSON lines in their LogEntry form.
+func encodeLines(logs []LogEntry) ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	for _, log := range logs {
+		if err := encoder.Encode(log); err != nil {
+			return nil, fmt.Errorf("failed to encode log entry: %w", err)
+		}
+	}
+	return buffer.Bytes(), nil
+}
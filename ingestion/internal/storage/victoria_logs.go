@@ -2,15 +2,60 @@ package storage
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
+	"math/rand/v2"
 	"net/http"
+	"net/url"
+	"slices"
+	"strings"
 	"time"
+
+	"github.com/bizjs/Lograil/pkg/tenant"
+)
+
+const (
+	// DefaultMsgField is the VictoriaLogs field holding the log message.
+	DefaultMsgField = "_msg"
+	// DefaultTimeField is the VictoriaLogs field holding the log timestamp.
+	DefaultTimeField = "_time"
 )
 
 type VictoriaLogsClient struct {
 	baseURL    string
 	httpClient *http.Client
+	options    VictoriaLogsOptions
+	breaker    *CircuitBreaker
+}
+
+// VictoriaLogsOptions controls how log entries are encoded and which
+// ingestion parameters are sent to VictoriaLogs.
+type VictoriaLogsOptions struct {
+	// StreamFields are sent as _stream_fields and define the log streams.
+	StreamFields []string
+	// TimeField is the name of the field carrying the entry timestamp.
+	TimeField string
+	// MsgField is the name of the field carrying the entry message.
+	MsgField string
+
+	// Timeout bounds a single HTTP request.
+	Timeout time.Duration
+	// MaxRetries is the number of additional attempts for retryable errors.
+	MaxRetries int
+	// RetryBaseDelay and RetryMaxDelay bound the jittered exponential backoff.
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+
+	// BreakerThreshold is the number of consecutive failed writes that opens
+	// the circuit breaker; zero disables it.
+	BreakerThreshold int
+	// BreakerCooldown is how long the breaker stays open before a trial write.
+	BreakerCooldown time.Duration
+
+	// Tenant maps each entry's project onto a VictoriaLogs tenant. The zero
+	// value writes everything to the default tenant.
+	Tenant tenant.Mapping
 }
 
 type LogEntry struct {
@@ -19,14 +64,39 @@ type LogEntry struct {
 	Message   string                 `json:"message"`
 	Source    string                 `json:"source"`
 	Fields    map[string]interface{} `json:"fields,omitempty"`
+
+	// ProjectID is the authenticated project the entry belongs to. It is
+	// set by the ingestion service, never by clients.
+	ProjectID int `json:"project_id,omitempty"`
 }
 
-func NewVictoriaLogsClient(baseURL string) (*VictoriaLogsClient, error) {
+func NewVictoriaLogsClient(baseURL string, opts VictoriaLogsOptions) (*VictoriaLogsClient, error) {
+	if opts.TimeField == "" {
+		opts.TimeField = DefaultTimeField
+	}
+	if opts.MsgField == "" {
+		opts.MsgField = DefaultMsgField
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	if opts.RetryBaseDelay <= 0 {
+		opts.RetryBaseDelay = 100 * time.Millisecond
+	}
+	if opts.RetryMaxDelay < opts.RetryBaseDelay {
+		opts.RetryMaxDelay = opts.RetryBaseDelay
+	}
+	if opts.BreakerCooldown <= 0 {
+		opts.BreakerCooldown = 30 * time.Second
+	}
+
 	return &VictoriaLogsClient{
-		baseURL: baseURL,
+		baseURL: strings.TrimRight(baseURL, "/"),
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout: opts.Timeout,
 		},
+		options: opts,
+		breaker: NewCircuitBreaker(opts.BreakerThreshold, opts.BreakerCooldown),
 	}, nil
 }
 
@@ -35,61 +105,179 @@ func (v *VictoriaLogsClient) Close() error {
 	return nil
 }
 
-func (v *VictoriaLogsClient) WriteLogs(logs []LogEntry) error {
+func (v *VictoriaLogsClient) Write(logs []LogEntry) error {
 	if len(logs) == 0 {
 		return nil
 	}
 
-	// Convert logs to VictoriaLogs format
-	// VictoriaLogs expects logs in a specific JSON format
-	var buffer bytes.Buffer
+	if !v.breaker.Allow() {
+		return ErrCircuitOpen
+	}
 
-	for _, log := range logs {
-		// Format log entry for VictoriaLogs
-		// This is a simplified implementation - actual VictoriaLogs format may vary
-		logLine := fmt.Sprintf(
-			"%s [%s] %s: %s",
-			log.Timestamp.Format(time.RFC3339),
-			log.Level,
-			log.Source,
-			log.Message,
-		)
-
-		// Add structured fields if present
-		if len(log.Fields) > 0 {
-			logLine += " | "
-			for k, v := range log.Fields {
-				logLine += fmt.Sprintf("%s=%v ", k, v)
+	// Each project is written with its own tenant, so one request per project.
+	// Every group is attempted, and only those that failed transiently are
+	// reported as remaining, so a retry does not duplicate the others.
+	var err, retryErr error
+	var remaining []LogEntry
+	for _, group := range groupByProject(logs) {
+		groupErr := v.writeProject(group.projectID, group.logs)
+		switch {
+		case groupErr == nil:
+		case IsPermanent(groupErr):
+			if err == nil {
+				err = groupErr
+			}
+		default:
+			if retryErr == nil {
+				retryErr = groupErr
 			}
+			remaining = append(remaining, group.logs...)
+		}
+	}
+	if retryErr != nil {
+		err = retryErr
+		if len(remaining) < len(logs) {
+			err = &PartialWriteError{Remaining: remaining, Err: retryErr}
+		}
+	}
+
+	// Rejected payloads still mean VictoriaLogs is reachable
+	if err == nil || IsPermanent(err) {
+		v.breaker.Success()
+	} else {
+		v.breaker.Failure()
+	}
+	return err
+}
+
+func (v *VictoriaLogsClient) writeProject(projectID int, logs []LogEntry) error {
+	// Encode each entry as a single JSON line
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	for _, log := range logs {
+		if err := encoder.Encode(v.encodeEntry(log)); err != nil {
+			return fmt.Errorf("failed to encode log entry: %w", err)
+		}
+	}
+
+	// Send to VictoriaLogs ingest endpoint, retrying transient failures
+	payload := buffer.Bytes()
+	var err error
+	for attempt := 0; attempt <= v.options.MaxRetries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(v.backoff(attempt))
+		}
+
+		err = v.send(projectID, payload)
+		if err == nil || !IsRetryable(err) {
+			break
 		}
+	}
+
+	return err
+}
+
+type projectLogs struct {
+	projectID int
+	logs      []LogEntry
+}
 
-		buffer.WriteString(logLine + "\n")
+// groupByProject splits logs by project, keeping their relative order.
+func groupByProject(logs []LogEntry) []projectLogs {
+	var groups []projectLogs
+	index := make(map[int]int)
+	for _, log := range logs {
+		i, ok := index[log.ProjectID]
+		if !ok {
+			i = len(groups)
+			index[log.ProjectID] = i
+			groups = append(groups, projectLogs{projectID: log.ProjectID})
+		}
+		groups[i].logs = append(groups[i].logs, log)
 	}
+	return groups
+}
 
-	// Send to VictoriaLogs ingest endpoint
-	url := fmt.Sprintf("%s/insert/jsonl", v.baseURL)
-	req, err := http.NewRequest("POST", url, &buffer)
+func (v *VictoriaLogsClient) send(projectID int, payload []byte) error {
+	req, err := http.NewRequest("POST", v.insertURL(), bytes.NewReader(payload))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/stream+json")
+	if v.options.Tenant.Mode != "" {
+		v.options.Tenant.SetHeaders(req.Header, projectID)
+	}
 
 	resp, err := v.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send logs to VictoriaLogs: %w", err)
+		return &TransportError{Err: err}
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("VictoriaLogs returned status %d: %s", resp.StatusCode, string(body))
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	return nil
 }
 
-func (v *VictoriaLogsClient) HealthCheck() error {
+// backoff returns a full-jitter exponential delay for the given attempt.
+func (v *VictoriaLogsClient) backoff(attempt int) time.Duration {
+	delay := v.options.RetryBaseDelay << (attempt - 1)
+	if delay <= 0 || delay > v.options.RetryMaxDelay {
+		delay = v.options.RetryMaxDelay
+	}
+	return time.Duration(rand.Int64N(int64(delay)) + 1)
+}
+
+// BreakerState returns the state of the write circuit breaker.
+func (v *VictoriaLogsClient) BreakerState() string {
+	return v.breaker.State()
+}
+
+// encodeEntry converts a LogEntry into the flat object VictoriaLogs expects.
+// Fields are promoted to top-level keys; the message, timestamp, level,
+// source and project always take precedence over a field with the same name.
+func (v *VictoriaLogsClient) encodeEntry(log LogEntry) map[string]interface{} {
+	record := make(map[string]interface{}, len(log.Fields)+4)
+	for k, val := range log.Fields {
+		record[k] = val
+	}
+
+	timestamp := log.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	record[v.options.MsgField] = log.Message
+	record[v.options.TimeField] = timestamp.UTC().Format(time.RFC3339Nano)
+	record["level"] = log.Level
+	record["source"] = log.Source
+	if value, ok := v.options.Tenant.StreamValue(log.ProjectID); ok {
+		record[tenant.StreamField] = value
+	}
+
+	return record
+}
+
+func (v *VictoriaLogsClient) insertURL() string {
+	params := url.Values{}
+	params.Set("_msg_field", v.options.MsgField)
+	params.Set("_time_field", v.options.TimeField)
+	streamFields := v.options.StreamFields
+	if _, ok := v.options.Tenant.StreamValue(0); ok && !slices.Contains(streamFields, tenant.StreamField) {
+		streamFields = append([]string{tenant.StreamField}, streamFields...)
+	}
+	if len(streamFields) > 0 {
+		params.Set("_stream_fields", strings.Join(streamFields, ","))
+	}
+
+	return fmt.Sprintf("%s/insert/jsonl?%s", v.baseURL, params.Encode())
+}
+
+func (v *VictoriaLogsClient) Health() error {
 	url := fmt.Sprintf("%s/health", v.baseURL)
 	resp, err := v.httpClient.Get(url)
 	if err != nil {
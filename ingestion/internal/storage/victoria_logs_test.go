@@ -0,0 +1,222 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bizjs/Lograil/pkg/tenant"
+)
+
+func TestWriteLogsEncodesJSONLines(t *testing.T) {
+	var lines []map[string]interface{}
+	var query map[string][]string
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		scanner := bufio.NewScanner(r.Body)
+		for scanner.Scan() {
+			var line map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+				t.Errorf("Line is not valid JSON: %q", scanner.Text())
+				continue
+			}
+			lines = append(lines, line)
+		}
+	}))
+	defer ts.Close()
+
+	client, err := NewVictoriaLogsClient(ts.URL, VictoriaLogsOptions{StreamFields: []string{"source", "level"}})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	entry := LogEntry{
+		Timestamp: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Level:     "error",
+		Message:   "payment failed",
+		Source:    "billing",
+		Fields:    map[string]interface{}{"user_id": "u-42", "level": "ignored"},
+	}
+	if err := client.Write([]LogEntry{entry}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	if len(lines) != 1 {
+		t.Fatalf("Expected 1 line, got %d", len(lines))
+	}
+	line := lines[0]
+	if line["_msg"] != "payment failed" || line["_time"] != "2024-01-02T03:04:05Z" {
+		t.Errorf("Unexpected message or time: %v", line)
+	}
+	if line["level"] != "error" || line["source"] != "billing" {
+		t.Errorf("Core fields must win over custom fields: %v", line)
+	}
+	if line["user_id"] != "u-42" {
+		t.Errorf("Expected user_id to be flattened to top level: %v", line)
+	}
+
+	if got := query["_stream_fields"]; len(got) != 1 || got[0] != "source,level" {
+		t.Errorf("Unexpected _stream_fields: %v", got)
+	}
+	if got := query["_msg_field"]; len(got) != 1 || got[0] != "_msg" {
+		t.Errorf("Unexpected _msg_field: %v", got)
+	}
+}
+
+func TestWriteLogsRetriesAndOpensBreaker(t *testing.T) {
+	var calls int
+	status := http.StatusServiceUnavailable
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	client, err := NewVictoriaLogsClient(ts.URL, VictoriaLogsOptions{
+		MaxRetries:       2,
+		RetryBaseDelay:   time.Millisecond,
+		BreakerThreshold: 1,
+		BreakerCooldown:  time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	logs := []LogEntry{{Level: "info", Message: "hello", Source: "test"}}
+
+	err = client.Write(logs)
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || !IsRetryable(err) {
+		t.Fatalf("Expected retryable StatusError, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Expected 3 attempts, got %d", calls)
+	}
+	if client.BreakerState() != BreakerOpen {
+		t.Errorf("Expected breaker to be open, got %s", client.BreakerState())
+	}
+
+	if err := client.Write(logs); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected ErrCircuitOpen, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("Open breaker must not contact VictoriaLogs, got %d calls", calls)
+	}
+}
+
+func TestWriteLogsDoesNotRetryPermanentErrors(t *testing.T) {
+	var calls int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer ts.Close()
+
+	client, err := NewVictoriaLogsClient(ts.URL, VictoriaLogsOptions{MaxRetries: 3, BreakerThreshold: 1})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	err = client.Write([]LogEntry{{Level: "info", Message: "hello", Source: "test"}})
+	if !IsPermanent(err) {
+		t.Fatalf("Expected permanent error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected a single attempt, got %d", calls)
+	}
+	if client.BreakerState() != BreakerClosed {
+		t.Errorf("Rejected payloads must not open the breaker, got %s", client.BreakerState())
+	}
+}
+
+func TestWriteLogsIsolatesProjects(t *testing.T) {
+	requests := map[string]int{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.Header.Get("AccountID")+":"+r.Header.Get("ProjectID")]++
+	}))
+	defer ts.Close()
+
+	client, err := NewVictoriaLogsClient(ts.URL, VictoriaLogsOptions{
+		Tenant: tenant.Mapping{Mode: tenant.ModeHeaders, AccountID: 7},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	logs := []LogEntry{
+		{Level: "info", Message: "a", Source: "test", ProjectID: 1},
+		{Level: "info", Message: "b", Source: "test", ProjectID: 2},
+		{Level: "info", Message: "c", Source: "test", ProjectID: 1},
+	}
+	if err := client.Write(logs); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	if len(requests) != 2 || requests["7:1"] != 1 || requests["7:2"] != 1 {
+		t.Errorf("Expected one request per project tenant, got %v", requests)
+	}
+}
+
+func TestWriteLogsReportsUnwrittenProjects(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("ProjectID") == "2" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	client, err := NewVictoriaLogsClient(ts.URL, VictoriaLogsOptions{
+		Tenant: tenant.Mapping{Mode: tenant.ModeHeaders},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	logs := []LogEntry{
+		{Level: "info", Message: "a", Source: "test", ProjectID: 1},
+		{Level: "info", Message: "b", Source: "test", ProjectID: 2},
+		{Level: "info", Message: "c", Source: "test", ProjectID: 1},
+	}
+	err = client.Write(logs)
+
+	var partialErr *PartialWriteError
+	if !errors.As(err, &partialErr) {
+		t.Fatalf("Expected PartialWriteError, got %v", err)
+	}
+	if !IsRetryable(err) {
+		t.Errorf("Expected the remainder to be retryable, got %v", err)
+	}
+	remaining := Unwritten(logs, err)
+	if len(remaining) != 1 || remaining[0].Message != "b" {
+		t.Errorf("Expected only project 2 to remain, got %v", remaining)
+	}
+}
+
+func TestEncodeEntryForcesProjectStreamField(t *testing.T) {
+	client, err := NewVictoriaLogsClient("http://localhost:9428", VictoriaLogsOptions{
+		StreamFields: []string{"source"},
+		Tenant:       tenant.Mapping{Mode: tenant.ModeStreamField},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	record := client.encodeEntry(LogEntry{
+		Message:   "spoofed",
+		ProjectID: 3,
+		Fields:    map[string]interface{}{tenant.StreamField: "99"},
+	})
+	if record[tenant.StreamField] != "3" {
+		t.Errorf("Expected project_id 3 to override client value, got %v", record[tenant.StreamField])
+	}
+	if got := client.insertURL(); !strings.Contains(got, "_stream_fields=project_id%2Csource") {
+		t.Errorf("Expected project_id stream field in %s", got)
+	}
+}
@@ -0,0 +1,50 @@
+// Package apikey holds the API key conventions shared by the control plane,
+// which issues keys, and the ingestion service, which verifies them.
+package apikey
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// Permission levels stored in the APIKey permissions field.
+const (
+	PermissionRead  = "read"
+	PermissionWrite = "write"
+	PermissionAdmin = "admin"
+)
+
+// Hash returns the value stored in APIKey.hashed_key for a raw key. The
+// hash is deterministic so that keys can be looked up by their hash.
+func Hash(rawKey string) string {
+	sum := sha256.Sum256([]byte(rawKey))
+	return hex.EncodeToString(sum[:])
+}
+
+// FromHeader returns the raw key sent either as "X-API-Key: <key>" or
+// "Authorization: Bearer <key>", or "" if there is none.
+func FromHeader(h http.Header) string {
+	if rawKey := h.Get("X-API-Key"); rawKey != "" {
+		return rawKey
+	}
+	if scheme, token, ok := strings.Cut(h.Get("Authorization"), " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// HasPermission reports whether a comma-separated permissions value grants
+// the required permission. Admin implies write, and write implies read.
+func HasPermission(permissions, required string) bool {
+	rank := map[string]int{PermissionRead: 1, PermissionWrite: 2, PermissionAdmin: 3}
+
+	for _, p := range strings.Split(permissions, ",") {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == required || (rank[p] > 0 && rank[p] >= rank[required]) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+// Package tenant maps Lograil projects onto VictoriaLogs tenants. The
+// ingestion service applies the mapping when writing and the control plane
+// applies the same mapping when querying, so a project only ever sees its
+// own logs.
+package tenant
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Mode selects how projects are isolated in VictoriaLogs.
+type Mode string
+
+const (
+	// ModeHeaders stores each project in its own VictoriaLogs tenant using
+	// the AccountID and ProjectID request headers.
+	ModeHeaders Mode = "headers"
+	// ModeStreamField stores all projects in one tenant and tags every
+	// entry with a mandatory project stream field.
+	ModeStreamField Mode = "stream_field"
+)
+
+// StreamField is the stream field carrying the project in ModeStreamField.
+const StreamField = "project_id"
+
+// Mapping describes how a project ID becomes a VictoriaLogs tenant.
+type Mapping struct {
+	Mode Mode
+	// AccountID is the VictoriaLogs AccountID used for all projects in
+	// ModeHeaders, and for the shared tenant in ModeStreamField.
+	AccountID uint32
+}
+
+// ParseMode validates a configured mode name.
+func ParseMode(value string) (Mode, error) {
+	switch Mode(value) {
+	case ModeHeaders, ModeStreamField:
+		return Mode(value), nil
+	default:
+		return "", fmt.Errorf("unknown tenant mode %q", value)
+	}
+}
+
+// SetHeaders sets the VictoriaLogs tenant headers for a project.
+func (m Mapping) SetHeaders(h http.Header, projectID int) {
+	h.Set("AccountID", strconv.FormatUint(uint64(m.AccountID), 10))
+	if m.Mode == ModeHeaders {
+		h.Set("ProjectID", strconv.Itoa(projectID))
+	} else {
+		h.Set("ProjectID", "0")
+	}
+}
+
+// StreamValue returns the value of StreamField for a project, and whether
+// the field must be set at all.
+func (m Mapping) StreamValue(projectID int) (string, bool) {
+	if m.Mode != ModeStreamField {
+		return "", false
+	}
+	return strconv.Itoa(projectID), true
+}
+
+// RestrictQuery adds the filters that confine a LogsQL query to a project.
+// VictoriaLogs applies extra_stream_filters on top of the user query, so
+// the query itself cannot widen the result set.
+func (m Mapping) RestrictQuery(params url.Values, projectID int) {
+	if value, ok := m.StreamValue(projectID); ok {
+		params.Set("extra_stream_filters", fmt.Sprintf("{%s=%q}", StreamField, value))
+	}
+}